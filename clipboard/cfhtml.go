@@ -0,0 +1,109 @@
+package clipboard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Windows puts HTML on the clipboard in the "HTML Format" (CF_HTML) layout:
+// a text header of Key:Value lines giving byte offsets into the data,
+// followed by the document, with the copied part between StartFragment and
+// EndFragment comments. Offsets count UTF-8 bytes from the start of the
+// header.
+// See https://learn.microsoft.com/windows/win32/dataxchg/html-clipboard-format.
+
+const (
+	cfHTMLStartMarker = "<!--StartFragment-->"
+	cfHTMLEndMarker   = "<!--EndFragment-->"
+
+	// Every offset is written as 10 digits, so the header has a fixed size.
+	cfHTMLHeaderFormat = "Version:0.9\r\nStartHTML:%010d\r\nEndHTML:%010d\r\nStartFragment:%010d\r\nEndFragment:%010d\r\n"
+)
+
+var cfHTMLHeaderLen = len(fmt.Sprintf(cfHTMLHeaderFormat, 0, 0, 0, 0))
+
+// encodeCFHTML wraps UTF-8 HTML in a CF_HTML header. A full document keeps
+// its own <html> and <body>, with the fragment markers placed just inside
+// the body; anything else is wrapped in a minimal document.
+func encodeCFHTML(markup []byte) []byte {
+	var doc []byte
+	if start, end, ok := htmlBody(markup); ok {
+		doc = make([]byte, 0, len(markup)+len(cfHTMLStartMarker)+len(cfHTMLEndMarker))
+		doc = append(doc, markup[:start]...)
+		doc = append(doc, cfHTMLStartMarker...)
+		doc = append(doc, markup[start:end]...)
+		doc = append(doc, cfHTMLEndMarker...)
+		doc = append(doc, markup[end:]...)
+	} else {
+		doc = []byte("<html><body>" + cfHTMLStartMarker + string(markup) + cfHTMLEndMarker + "</body></html>")
+	}
+
+	startFragment := bytes.Index(doc, []byte(cfHTMLStartMarker)) + len(cfHTMLStartMarker)
+	endFragment := bytes.LastIndex(doc, []byte(cfHTMLEndMarker))
+
+	out := fmt.Appendf(nil, cfHTMLHeaderFormat,
+		cfHTMLHeaderLen, cfHTMLHeaderLen+len(doc),
+		cfHTMLHeaderLen+startFragment, cfHTMLHeaderLen+endFragment)
+	return append(out, doc...)
+}
+
+// htmlBody returns the byte range between the end of markup's <body> tag and
+// the start of its </body> tag, if it has both.
+func htmlBody(markup []byte) (start, end int, ok bool) {
+	lower := bytes.ToLower(markup)
+	open := bytes.Index(lower, []byte("<body"))
+	if open < 0 {
+		return 0, 0, false
+	}
+	gt := bytes.IndexByte(lower[open:], '>')
+	if gt < 0 {
+		return 0, 0, false
+	}
+	start = open + gt + 1
+	end = bytes.LastIndex(lower, []byte("</body"))
+	if end < start {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// decodeCFHTML returns the HTML document from CF_HTML data: the bytes from
+// StartHTML to EndHTML, or just the fragment if the header gives no
+// document offsets (StartHTML:-1, allowed since version 1.0).
+func decodeCFHTML(data []byte) ([]byte, error) {
+	data = bytes.TrimRight(data, "\x00")
+
+	offsets := map[string]int{}
+	rest := data
+	for len(rest) > 0 && rest[0] != '<' {
+		line := rest
+		if i := bytes.IndexAny(rest, "\r\n"); i >= 0 {
+			line, rest = rest[:i], bytes.TrimLeft(rest[i:], "\r\n")
+		} else {
+			rest = nil
+		}
+		key, value, ok := bytes.Cut(line, []byte(":"))
+		if !ok {
+			return nil, fmt.Errorf("malformed CF_HTML header line %q", line)
+		}
+		switch k := string(key); k {
+		case "StartHTML", "EndHTML", "StartFragment", "EndFragment":
+			n, err := strconv.Atoi(string(bytes.TrimSpace(value)))
+			if err != nil {
+				return nil, fmt.Errorf("malformed CF_HTML %s: %w", k, err)
+			}
+			offsets[k] = n
+		}
+	}
+
+	start, end := offsets["StartHTML"], offsets["EndHTML"]
+	if start <= 0 || end <= 0 {
+		start, end = offsets["StartFragment"], offsets["EndFragment"]
+	}
+	if start <= 0 || end < start || end > len(data) {
+		return nil, errors.New("invalid CF_HTML offsets")
+	}
+	return data[start:end], nil
+}
@@ -0,0 +1,121 @@
+package clipboard
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// cfHTMLOffsets parses the four offsets from an encoded header.
+func cfHTMLOffsets(t *testing.T, data []byte) map[string]int {
+	t.Helper()
+	offsets := map[string]int{}
+	for _, m := range regexp.MustCompile(`(StartHTML|EndHTML|StartFragment|EndFragment):(\d+)`).FindAllSubmatch(data, -1) {
+		n, err := strconv.Atoi(string(m[2]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		offsets[string(m[1])] = n
+	}
+	if len(offsets) != 4 {
+		t.Fatalf("header has %d offsets, want 4: %q", len(offsets), data)
+	}
+	return offsets
+}
+
+func TestEncodeCFHTML_Offsets(t *testing.T) {
+	tests := []struct {
+		name, in, fragment string
+	}{
+		{"fragment", "<b>bold</b>", "<b>bold</b>"},
+		{"multibyte", "<i>héllo — 世界</i>", "<i>héllo — 世界</i>"},
+		{"document", `<html><head><meta charset="utf-8"></head><BODY class="x"><p>para</p></BODY></html>`, "<p>para</p>"},
+	}
+	for _, tc := range tests {
+		data := encodeCFHTML([]byte(tc.in))
+		o := cfHTMLOffsets(t, data)
+
+		if o["StartHTML"] != cfHTMLHeaderLen || o["EndHTML"] != len(data) {
+			t.Errorf("%s: StartHTML=%d EndHTML=%d, want %d and %d", tc.name, o["StartHTML"], o["EndHTML"], cfHTMLHeaderLen, len(data))
+		}
+		if !strings.HasPrefix(string(data[o["StartHTML"]:]), "<html") {
+			t.Errorf("%s: StartHTML does not point at <html>: %q", tc.name, data[o["StartHTML"]:])
+		}
+		if got := string(data[o["StartFragment"]:o["EndFragment"]]); got != tc.fragment {
+			t.Errorf("%s: fragment = %q, want %q", tc.name, got, tc.fragment)
+		}
+	}
+}
+
+func TestDecodeCFHTML(t *testing.T) {
+	for _, in := range []string{"<b>bold</b>", "<i>héllo — 世界</i>"} {
+		encoded := encodeCFHTML([]byte(in))
+		doc, err := decodeCFHTML(append(encoded, 0))
+		if err != nil {
+			t.Fatalf("decodeCFHTML(%q): %v", encoded, err)
+		}
+		if !strings.Contains(string(doc), cfHTMLStartMarker+in+cfHTMLEndMarker) {
+			t.Errorf("decoded document %q lost the fragment %q", doc, in)
+		}
+	}
+}
+
+func TestDecodeCFHTML_FromBrowser(t *testing.T) {
+	// As written by Chrome: LF line endings, a SourceURL line, and offsets
+	// padded to 10 digits.
+	body := "<html>\n<body>\n" + cfHTMLStartMarker + "<a href=\"https://example.com\">link</a>" + cfHTMLEndMarker + "\n</body>\n</html>"
+	header := "Version:0.9\nStartHTML:0000000000\nEndHTML:0000000000\nStartFragment:0000000000\nEndFragment:0000000000\nSourceURL:https://example.com/page\n"
+	start := len(header)
+	fragStart := start + strings.Index(body, cfHTMLStartMarker) + len(cfHTMLStartMarker)
+	fragEnd := start + strings.Index(body, cfHTMLEndMarker)
+	header = strings.NewReplacer(
+		"StartHTML:0000000000", "StartHTML:"+pad10(start),
+		"EndHTML:0000000000", "EndHTML:"+pad10(start+len(body)),
+		"StartFragment:0000000000", "StartFragment:"+pad10(fragStart),
+		"EndFragment:0000000000", "EndFragment:"+pad10(fragEnd),
+	).Replace(header)
+
+	doc, err := decodeCFHTML([]byte(header + body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(doc) != body {
+		t.Errorf("decoded %q, want %q", doc, body)
+	}
+}
+
+func TestDecodeCFHTML_FragmentOnly(t *testing.T) {
+	frag := "<p>x</p>"
+	header := "Version:1.0\r\nStartHTML:-1\r\nEndHTML:-1\r\nStartFragment:" + pad10(0) + "\r\nEndFragment:" + pad10(0) + "\r\n"
+	data := header + frag
+	data = strings.Replace(data, "StartFragment:"+pad10(0), "StartFragment:"+pad10(len(header)), 1)
+	data = strings.Replace(data, "EndFragment:"+pad10(0), "EndFragment:"+pad10(len(header)+len(frag)), 1)
+
+	doc, err := decodeCFHTML([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(doc) != frag {
+		t.Errorf("decoded %q, want %q", doc, frag)
+	}
+}
+
+func TestDecodeCFHTML_Invalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"<html>no header</html>",
+		"Version:0.9\r\nStartHTML:abc\r\n",
+		"Version:0.9\r\nStartHTML:0000000040\r\nEndHTML:0000009999\r\n<html></html>",
+		"no colon here\r\n",
+	} {
+		if _, err := decodeCFHTML([]byte(in)); err == nil {
+			t.Errorf("decodeCFHTML(%q): expected error", in)
+		}
+	}
+}
+
+func pad10(n int) string {
+	s := strconv.Itoa(n)
+	return strings.Repeat("0", 10-len(s)) + s
+}
@@ -1,10 +1,11 @@
 package clipboard
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
-	"log"
+	"errors"
+	"fmt"
 	"sync"
+
+	"github.com/mindmorass/paperclip/logging"
 )
 
 // ContentType identifies the type of clipboard content
@@ -13,35 +14,173 @@ type ContentType byte
 const (
 	TypeText  ContentType = 0x01
 	TypeImage ContentType = 0x02
+	TypeHTML  ContentType = 0x03 // HTML markup, UTF-8
+	TypeRTF   ContentType = 0x04 // RTF document
 )
 
+// Known reports whether t is a content type this version can write to the
+// OS clipboard. Receivers drop anything else rather than guess, so new types
+// can be added to the wire format without older versions mangling them.
+func (t ContentType) Known() bool {
+	switch t {
+	case TypeText, TypeImage, TypeHTML, TypeRTF:
+		return true
+	}
+	return false
+}
+
+// String returns the name used for t in logs and configuration.
+func (t ContentType) String() string {
+	switch t {
+	case TypeText:
+		return "text"
+	case TypeImage:
+		return "image"
+	case TypeHTML:
+		return "html"
+	case TypeRTF:
+		return "rtf"
+	}
+	return fmt.Sprintf("type %#x", byte(t))
+}
+
+// ParseContentType returns the content type named name ("text", "image",
+// "html" or "rtf").
+func ParseContentType(name string) (ContentType, error) {
+	for _, t := range []ContentType{TypeText, TypeImage, TypeHTML, TypeRTF} {
+		if t.String() == name {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown content type %q (want \"text\", \"image\", \"html\" or \"rtf\")", name)
+}
+
 // Content represents clipboard data with its type and hash
 type Content struct {
 	Type ContentType
 	Data []byte
 	Hash string
+
+	// Format is the platform type the content was read from, e.g.
+	// "public.png" on macOS. It is local metadata and is not synced.
+	Format string
+
+	// Concealed is set when the source application marked the content as
+	// a secret (macOS org.nspasteboard.ConcealedType, Windows
+	// ExcludeClipboardContentFromMonitorProcessing). Local metadata.
+	Concealed bool
 }
 
 // Clipboard handles clipboard operations
 type Clipboard struct {
 	mu       sync.Mutex
 	lastHash string
-	logger   *log.Logger
+	logger   logging.Logger
+
+	// Platform backend, swappable in tests. changeToken returns the OS
+	// clipboard change counter (macOS changeCount, Windows sequence number),
+	// or ok=false where none is available.
+	readOS      func() (*Content, error)
+	writeOS     func(*Content) error
+	changeToken func() (token uint64, ok bool)
+	// selfToken is the change counter observed right after our last Write.
+	selfToken   uint64
+	selfTokenOK bool
+
+	pbTypes []string // macOS pasteboard read priority; nil = DefaultPasteboardTypes
+
+	// Last-hash persistence; see PersistLastHash. hashFile is empty when
+	// disabled, and savedDigest is the digest the file currently holds.
+	hashFile    string
+	hashKey     []byte
+	savedDigest string
+
+	noImages bool // Read ignores images; see SetNoImages
 }
 
 // New creates a new Clipboard instance
-func New(logger *log.Logger) *Clipboard {
-	return &Clipboard{logger: logger}
+func New(logger logging.Logger) *Clipboard {
+	c := &Clipboard{logger: logger, changeToken: osChangeToken}
+	c.readOS = c.read
+	c.writeOS = c.write
+	return c
+}
+
+// Read returns the current clipboard content (text or image).
+//
+// If the OS change counter has not moved since our own last Write, the
+// content is reported with the hash that was written, even when the OS
+// re-encoded it on the way in (PNG re-compression, DIB padding, etc.), so
+// the poller never mistakes its own write for a new local copy.
+func (c *Clipboard) Read() (*Content, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Sample the counter before reading: if the clipboard changes in between,
+	// the next poll sees a new counter and picks the change up.
+	token, ok := c.changeToken()
+	content, err := c.readOS()
+	if err != nil {
+		return nil, err
+	}
+	if ok && c.selfTokenOK && token == c.selfToken {
+		content.Hash = c.lastHash
+	}
+	return content, nil
+}
+
+// Write sets the clipboard content and records it as self-originated.
+func (c *Clipboard) Write(content *Content) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.writeOS(content); err != nil {
+		return err
+	}
+	c.lastHash = content.Hash
+	c.saveHashLocked(content.Hash)
+	c.selfToken, c.selfTokenOK = c.changeToken()
+	return nil
+}
+
+// SetNoImages makes Read ignore images on the clipboard and report the
+// next available type instead, usually text. Use it where image reads cannot
+// work or are not wanted, e.g. on a headless machine.
+func (c *Clipboard) SetNoImages(noImages bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.noImages = noImages
+}
+
+// ErrNoChangeToken is returned by ChangeToken on platforms without a cheap
+// clipboard change counter; callers should fall back to Read and hashing.
+var ErrNoChangeToken = errors.New("clipboard change token not available")
+
+// ChangeToken returns the OS clipboard change counter (macOS changeCount,
+// Windows sequence number). It moves whenever the clipboard changes, so a
+// poller can skip Read and hashing while it stays the same.
+func (c *Clipboard) ChangeToken() (uint64, error) {
+	token, ok := c.changeToken()
+	if !ok {
+		return 0, ErrNoChangeToken
+	}
+	return token, nil
 }
 
-// HasChanged returns true if clipboard content differs from last known hash
+// HasChanged returns true if clipboard content differs from last known hash.
+// Until the first change, content synced before a restart also counts as
+// known; see PersistLastHash.
 func (c *Clipboard) HasChanged(currentHash string) bool {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	if c.lastHash == "" && c.savedHashLocked(currentHash) {
+		c.lastHash = currentHash
+	}
 	return currentHash != c.lastHash
 }
 
-// SetLastHash updates the last known hash (used after sending)
+// SetLastHash updates the last known hash. It is not persisted: use
+// MarkSent once the content has been published.
 func (c *Clipboard) SetLastHash(hash string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -54,8 +193,3 @@ func (c *Clipboard) GetLastHash() string {
 	defer c.mu.Unlock()
 	return c.lastHash
 }
-
-func hashData(data []byte) string {
-	h := sha256.Sum256(data)
-	return hex.EncodeToString(h[:])
-}
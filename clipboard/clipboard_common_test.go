@@ -0,0 +1,170 @@
+package clipboard
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeOS simulates an OS clipboard that bumps a change counter on every
+// change and may transform what it stores, as macOS and Windows do when
+// re-encoding images.
+type fakeOS struct {
+	seq     uint64
+	data    []byte
+	mutate  func([]byte) []byte
+	noToken bool
+}
+
+func (f *fakeOS) set(data []byte) {
+	f.seq++
+	if f.mutate != nil {
+		data = f.mutate(data)
+	}
+	f.data = data
+}
+
+func newTestClipboard(f *fakeOS) *Clipboard {
+	c := New(log.New(&bytes.Buffer{}, "", 0))
+	c.readOS = func() (*Content, error) {
+		return &Content{Type: TypeImage, Data: f.data, Hash: HashData(f.data)}, nil
+	}
+	c.writeOS = func(content *Content) error {
+		f.set(content.Data)
+		return nil
+	}
+	c.changeToken = func() (uint64, bool) { return f.seq, !f.noToken }
+	return c
+}
+
+func TestSelfWriteNotReportedAsChange(t *testing.T) {
+	f := &fakeOS{mutate: func(b []byte) []byte { return append(b, 0x00) }}
+	c := newTestClipboard(f)
+
+	written := &Content{Type: TypeImage, Data: []byte("png-bytes"), Hash: HashData([]byte("png-bytes"))}
+	if err := c.Write(written); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	got, err := c.Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if bytes.Equal(got.Data, written.Data) {
+		t.Fatal("fake OS did not mutate the content; test is not exercising the fix")
+	}
+	if c.HasChanged(got.Hash) {
+		t.Error("self-written content read back altered was reported as changed (would re-publish)")
+	}
+
+	// A genuine copy by another app moves the counter and must be detected.
+	f.set([]byte("user copy"))
+	got, err = c.Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if !c.HasChanged(got.Hash) {
+		t.Error("a real clipboard change after our write was suppressed")
+	}
+}
+
+func TestSelfWriteFallsBackToHashWithoutToken(t *testing.T) {
+	f := &fakeOS{mutate: func(b []byte) []byte { return append(b, 0x00) }, noToken: true}
+	c := newTestClipboard(f)
+
+	if err := c.Write(&Content{Type: TypeImage, Data: []byte("png-bytes"), Hash: HashData([]byte("png-bytes"))}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	got, err := c.Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if !c.HasChanged(got.Hash) {
+		t.Error("without a change token Read must report the hash of what it actually read")
+	}
+}
+
+func TestParseContentType(t *testing.T) {
+	for _, want := range []ContentType{TypeText, TypeImage, TypeHTML, TypeRTF} {
+		got, err := ParseContentType(want.String())
+		if err != nil || got != want {
+			t.Errorf("ParseContentType(%q) = %v, %v; want %v", want.String(), got, err, want)
+		}
+	}
+	if _, err := ParseContentType("video"); err == nil {
+		t.Error("ParseContentType(\"video\") succeeded, want error")
+	}
+}
+
+func TestPersistLastHash_SurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "last_hash")
+	f := &fakeOS{}
+	f.set([]byte("before restart"))
+
+	c := newTestClipboard(f)
+	if err := c.PersistLastHash(path); err != nil {
+		t.Fatalf("PersistLastHash: %v", err)
+	}
+	content, err := c.Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	c.MarkSent(content.Hash)
+
+	restarted := newTestClipboard(f)
+	if err := restarted.PersistLastHash(path); err != nil {
+		t.Fatalf("PersistLastHash after restart: %v", err)
+	}
+	if restarted.HasChanged(content.Hash) {
+		t.Error("content seen before the restart reported as changed")
+	}
+}
+
+func TestPersistLastHash_StoresKeyedDigest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "last_hash")
+	c := newTestClipboard(&fakeOS{})
+	if err := c.PersistLastHash(path); err != nil {
+		t.Fatalf("PersistLastHash: %v", err)
+	}
+	hash := HashData([]byte("secret"))
+
+	c.SetLastHash(hash)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("SetLastHash wrote the hash file (stat err %v)", err)
+	}
+
+	c.MarkSent(hash)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading hash file: %v", err)
+	}
+	if strings.Contains(string(data), hash) {
+		t.Error("hash file holds the raw content hash")
+	}
+
+	// Under a different install's key the same content is not recognised.
+	other := newTestClipboard(&fakeOS{})
+	otherDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(otherDir, "last_hash"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.PersistLastHash(filepath.Join(otherDir, "last_hash")); err != nil {
+		t.Fatalf("PersistLastHash: %v", err)
+	}
+	if !other.HasChanged(hash) {
+		t.Error("digest matched under another install's key")
+	}
+}
+
+func TestPersistLastHash_MissingFile(t *testing.T) {
+	c := newTestClipboard(&fakeOS{})
+	if err := c.PersistLastHash(filepath.Join(t.TempDir(), "last_hash")); err != nil {
+		t.Fatalf("PersistLastHash: %v", err)
+	}
+	if got := c.GetLastHash(); got != "" {
+		t.Errorf("GetLastHash = %q, want empty", got)
+	}
+}
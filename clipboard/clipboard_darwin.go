@@ -7,84 +7,44 @@ import (
 	"encoding/base64"
 	"fmt"
 	"os/exec"
+	"strconv"
 )
 
-// Read returns the current clipboard content (text or image)
-func (c *Clipboard) Read() (*Content, error) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	// Try to read image first (PNG from clipboard)
-	imgData, imgErr := c.readImage()
-	if imgErr == nil && len(imgData) > 0 {
-		hash := hashData(imgData)
-		return &Content{
-			Type: TypeImage,
-			Data: imgData,
-			Hash: hash,
-		}, nil
-	}
-
-	// Fall back to text
-	textData, textErr := c.readText()
-	if textErr != nil {
-		return nil, textErr
-	}
-
-	hash := hashData(textData)
-	return &Content{
-		Type: TypeText,
-		Data: textData,
-		Hash: hash,
-	}, nil
+// read returns the first available pasteboard type in the configured
+// priority order.
+func (c *Clipboard) read() (*Content, error) {
+	return readPasteboard(c.pasteboardTypes())
 }
 
-// Write sets the clipboard content
-func (c *Clipboard) Write(content *Content) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	var err error
+// write sets the clipboard content.
+func (c *Clipboard) write(content *Content) error {
 	switch content.Type {
 	case TypeImage:
-		err = c.writeImage(content.Data)
+		return c.writeImage(content.Data)
+	case TypeHTML:
+		return c.writeRich(content.Data, "NSPasteboardTypeHTML", "initWithHTML")
+	case TypeRTF:
+		return c.writeRich(content.Data, "NSPasteboardTypeRTF", "initWithRTF")
 	default:
-		err = c.writeText(content.Data)
+		return c.writeText(content.Data)
 	}
-
-	if err == nil {
-		c.lastHash = content.Hash
-	}
-	return err
 }
 
-func (c *Clipboard) readText() ([]byte, error) {
-	// Read text via NSPasteboard → UTF-8 → base64 to avoid pbpaste
-	// encoding/normalization issues (locale-dependent, line-ending
-	// conversion, Unicode normalization).
+// osChangeToken returns NSPasteboard's changeCount, which increments on
+// every change to the general pasteboard.
+func osChangeToken() (uint64, bool) {
 	script := `use framework "AppKit"
-use framework "Foundation"
-use scripting additions
-
-set theClipboard to current application's NSPasteboard's generalPasteboard()
-set theString to theClipboard's stringForType:(current application's NSPasteboardTypeString)
-if theString is missing value then
-    error "No text"
-end if
-set nsData to theString's dataUsingEncoding:(current application's NSUTF8StringEncoding)
-return (nsData's base64EncodedStringWithOptions:0) as text`
+return (current application's NSPasteboard's generalPasteboard()'s changeCount()) as integer`
 
-	cmd := exec.Command("osascript", "-e", script)
-	output, err := cmd.Output()
+	output, err := runOSAScript(script)
 	if err != nil {
-		return nil, err
+		return 0, false
 	}
-
-	output = bytes.TrimSpace(output)
-	if len(output) == 0 {
-		return []byte{}, nil
+	n, err := strconv.ParseUint(string(bytes.TrimSpace(output)), 10, 64)
+	if err != nil {
+		return 0, false
 	}
-	return base64.StdEncoding.DecodeString(string(output))
+	return n, true
 }
 
 func (c *Clipboard) writeText(data []byte) error {
@@ -107,62 +67,30 @@ theClipboard's setString:theString forType:(current application's NSPasteboardTy
 	return cmd.Run()
 }
 
-// maxImageBytes caps the clipboard image size we will accept (16 MB).
-// Images larger than this are silently ignored to prevent OOM during
-// TIFF→PNG conversion of arbitrarily large clipboard contents.
-const maxImageBytes = 16 * 1024 * 1024
-
-func (c *Clipboard) readImage() ([]byte, error) {
-	// Use osascript to get clipboard as PNG data (convert from TIFF if needed)
-	// macOS clipboard often stores images as TIFF, so we convert to PNG for portability
-	script := `use framework "AppKit"
+func (c *Clipboard) writeImage(data []byte) error {
+	// Use osascript to write PNG to clipboard
+	// Note: Must use class "NSData" syntax for proper class resolution
+	encoded := base64.StdEncoding.EncodeToString(data)
+	script := fmt.Sprintf(`use framework "AppKit"
 use framework "Foundation"
 use scripting additions
 
+set b64Data to "%s"
+set nsData to current application's class "NSData"'s alloc()'s initWithBase64EncodedString:b64Data options:0
 set theClipboard to current application's NSPasteboard's generalPasteboard()
-
--- Try PNG first
-set imgData to theClipboard's dataForType:(current application's NSPasteboardTypePNG)
-
--- Fall back to TIFF and convert to PNG
-if imgData is missing value then
-    set tiffData to theClipboard's dataForType:(current application's NSPasteboardTypeTIFF)
-    if tiffData is missing value then
-        error "No image"
-    end if
-
-    -- Convert TIFF to PNG via NSBitmapImageRep
-    set imgRep to current application's NSBitmapImageRep's imageRepWithData:tiffData
-    if imgRep is missing value then
-        error "No image"
-    end if
-    set imgData to imgRep's representationUsingType:(current application's NSBitmapImageFileTypePNG) |properties|:(missing value)
-end if
-
-return (imgData's base64EncodedStringWithOptions:0) as text`
+theClipboard's clearContents()
+theClipboard's setData:nsData forType:(current application's NSPasteboardTypePNG)
+`, encoded)
 
 	cmd := exec.Command("osascript", "-e", script)
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
-
-	// Decode base64
-	output = bytes.TrimSpace(output)
-	decoded, err := base64.StdEncoding.DecodeString(string(output))
-	if err != nil {
-		return nil, err
-	}
-
-	if len(decoded) > maxImageBytes {
-		return nil, fmt.Errorf("image too large (%d bytes, max %d)", len(decoded), maxImageBytes)
-	}
-	return decoded, nil
+	return cmd.Run()
 }
 
-func (c *Clipboard) writeImage(data []byte) error {
-	// Use osascript to write PNG to clipboard
-	// Note: Must use class "NSData" syntax for proper class resolution
+// writeRich puts a rich-text document on the pasteboard as pbType, with a
+// plain-text rendering alongside so apps that only accept text can still
+// paste. initSelector is the NSAttributedString initializer that parses the
+// document (initWithHTML or initWithRTF).
+func (c *Clipboard) writeRich(data []byte, pbType, initSelector string) error {
 	encoded := base64.StdEncoding.EncodeToString(data)
 	script := fmt.Sprintf(`use framework "AppKit"
 use framework "Foundation"
@@ -170,10 +98,14 @@ use scripting additions
 
 set b64Data to "%s"
 set nsData to current application's class "NSData"'s alloc()'s initWithBase64EncodedString:b64Data options:0
+set attr to current application's NSAttributedString's alloc()'s %s:nsData documentAttributes:(missing value)
 set theClipboard to current application's NSPasteboard's generalPasteboard()
 theClipboard's clearContents()
-theClipboard's setData:nsData forType:(current application's NSPasteboardTypePNG)
-`, encoded)
+theClipboard's setData:nsData forType:(current application's %s)
+if attr is not missing value then
+	theClipboard's setString:(attr's |string|()) forType:(current application's NSPasteboardTypeString)
+end if
+`, encoded, initSelector, pbType)
 
 	cmd := exec.Command("osascript", "-e", script)
 	return cmd.Run()
@@ -0,0 +1,19 @@
+//go:build !darwin && !windows
+
+package clipboard
+
+import "errors"
+
+var errUnsupported = errors.New("clipboard access is not supported on this platform")
+
+func (c *Clipboard) read() (*Content, error) {
+	return nil, errUnsupported
+}
+
+func (c *Clipboard) write(content *Content) error {
+	return errUnsupported
+}
+
+func osChangeToken() (uint64, bool) {
+	return 0, false
+}
@@ -23,6 +23,7 @@ var (
 	setClipboardData    = user32.NewProc("SetClipboardData")
 	isClipboardFormatAvailable = user32.NewProc("IsClipboardFormatAvailable")
 	registerClipboardFormatW   = user32.NewProc("RegisterClipboardFormatW")
+	getClipboardSequenceNumber = user32.NewProc("GetClipboardSequenceNumber")
 
 	globalAlloc = kernel32.NewProc("GlobalAlloc")
 	globalFree  = kernel32.NewProc("GlobalFree")
@@ -34,42 +35,77 @@ var (
 const (
 	cfUnicodeText = 13
 	cfDIB         = 8
+	cfDIBV5       = 17
 	gmemMoveable  = 0x0002
 )
 
-var cfPNG uint32 // Registered at init
+var cfPNG uint32  // Registered at init
+var cfRTF uint32  // Registered at init
+var cfHTML uint32 // Registered at init
 
 func init() {
 	// Register PNG format - Windows supports this on modern versions
 	name, _ := syscall.UTF16PtrFromString("PNG")
 	ret, _, _ := registerClipboardFormatW.Call(uintptr(unsafe.Pointer(name)))
 	cfPNG = uint32(ret)
+
+	name, _ = syscall.UTF16PtrFromString("Rich Text Format")
+	ret, _, _ = registerClipboardFormatW.Call(uintptr(unsafe.Pointer(name)))
+	cfRTF = uint32(ret)
+
+	name, _ = syscall.UTF16PtrFromString("HTML Format")
+	ret, _, _ = registerClipboardFormatW.Call(uintptr(unsafe.Pointer(name)))
+	cfHTML = uint32(ret)
+
+	name, _ = syscall.UTF16PtrFromString("ExcludeClipboardContentFromMonitorProcessing")
+	ret, _, _ = registerClipboardFormatW.Call(uintptr(unsafe.Pointer(name)))
+	cfExcludeFromMonitor = uint32(ret)
+
+	name, _ = syscall.UTF16PtrFromString("CanIncludeInClipboardHistory")
+	ret, _, _ = registerClipboardFormatW.Call(uintptr(unsafe.Pointer(name)))
+	cfCanIncludeInHistory = uint32(ret)
 }
 
-// Read returns the current clipboard content (text or image)
-func (c *Clipboard) Read() (*Content, error) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// Formats password managers set to mark a secret. The first one's presence
+// asks clipboard monitors to ignore the content; the second holds a DWORD
+// that is zero when the content must not be kept in clipboard history.
+var cfExcludeFromMonitor, cfCanIncludeInHistory uint32
 
+// concealed reports whether the open clipboard carries a secret marker.
+func concealed() bool {
+	if cfExcludeFromMonitor != 0 {
+		if ok, _, _ := isClipboardFormatAvailable.Call(uintptr(cfExcludeFromMonitor)); ok != 0 {
+			return true
+		}
+	}
+	if cfCanIncludeInHistory != 0 {
+		if data, err := getFormat(cfCanIncludeInHistory); err == nil && len(data) >= 4 && binary.LittleEndian.Uint32(data) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// read returns the current clipboard content (text or image).
+func (c *Clipboard) read() (*Content, error) {
 	if err := openCB(); err != nil {
 		return nil, err
 	}
 	defer closeClipboard.Call()
 
-	// Try PNG image first
-	if cfPNG != 0 {
-		if data, err := getFormat(cfPNG); err == nil && len(data) > 0 {
-			hash := hashData(data)
-			return &Content{Type: TypeImage, Data: data, Hash: hash}, nil
+	secret := concealed()
+
+	if !c.noImages {
+		if content := readImage(); content != nil {
+			content.Concealed = secret
+			return content, nil
 		}
 	}
 
-	// Try DIB image and convert to PNG
-	if data, err := getFormat(cfDIB); err == nil && len(data) > 0 {
-		pngData, err := dibToPNG(data)
-		if err == nil && len(pngData) > 0 {
-			hash := hashData(pngData)
-			return &Content{Type: TypeImage, Data: pngData, Hash: hash}, nil
+	for _, uti := range c.richTypes() {
+		if content := readRich(uti); content != nil {
+			content.Concealed = secret
+			return content, nil
 		}
 	}
 
@@ -81,15 +117,67 @@ func (c *Clipboard) Read() (*Content, error) {
 
 	// Convert UTF-16LE to UTF-8
 	text := utf16ToUTF8(data)
-	hash := hashData(text)
-	return &Content{Type: TypeText, Data: text, Hash: hash}, nil
+	hash := HashData(text)
+	return &Content{Type: TypeText, Data: text, Hash: hash, Concealed: secret}, nil
+}
+
+// readRich returns the clipboard's CF_HTML (for public.html) or CF_RTF (for
+// public.rtf) document, or nil if there is none. The clipboard must be open.
+func readRich(uti string) *Content {
+	switch uti {
+	case UTIHTML:
+		if cfHTML == 0 {
+			return nil
+		}
+		data, err := getFormat(cfHTML)
+		if err != nil {
+			return nil
+		}
+		if doc, err := decodeCFHTML(data); err == nil && len(doc) > 0 {
+			return &Content{Type: TypeHTML, Data: doc, Hash: HashData(doc)}
+		}
+	case UTIRTF:
+		if cfRTF == 0 {
+			return nil
+		}
+		data, err := getFormat(cfRTF)
+		if err != nil {
+			return nil
+		}
+		// CF_RTF is NUL-terminated, and GlobalSize may round up.
+		if doc := bytes.TrimRight(data, "\x00"); len(doc) > 0 {
+			return &Content{Type: TypeRTF, Data: doc, Hash: HashData(doc)}
+		}
+	}
+	return nil
 }
 
-// Write sets the clipboard content
-func (c *Clipboard) Write(content *Content) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// readImage returns the clipboard image as PNG, or nil if there is none.
+// The clipboard must be open.
+func readImage() *Content {
+	// Try PNG image first
+	if cfPNG != 0 {
+		if data, err := getFormat(cfPNG); err == nil && len(data) > 0 {
+			return &Content{Type: TypeImage, Data: data, Hash: HashData(data)}
+		}
+	}
 
+	// Try DIB image and convert to PNG. CF_DIBV5 comes first: it is what
+	// apps that support transparency set, and the CF_DIB Windows
+	// synthesizes from it may have lost the alpha.
+	for _, format := range []uint32{cfDIBV5, cfDIB} {
+		if data, err := getFormat(format); err == nil && len(data) > 0 {
+			if pngData, err := dibToPNG(data); err == nil && len(pngData) > 0 {
+				return &Content{Type: TypeImage, Data: pngData, Hash: HashData(pngData)}
+			}
+		}
+	}
+	return nil
+}
+
+// write sets the clipboard content. The clipboard is closed before write
+// returns, so a change token taken afterwards reflects the completed write.
+func (c *Clipboard) write(content *Content) error {
 	if err := openCB(); err != nil {
 		return err
 	}
@@ -97,18 +185,26 @@ func (c *Clipboard) Write(content *Content) error {
 
 	emptyClipboard.Call()
 
-	var err error
 	switch content.Type {
 	case TypeImage:
-		err = c.writeImage(content.Data)
+		return c.writeImage(content.Data)
+	case TypeHTML:
+		return c.writeHTML(content.Data)
+	case TypeRTF:
+		return c.writeRTF(content.Data)
 	default:
-		err = c.writeText(content.Data)
+		return c.writeText(content.Data)
 	}
+}
 
-	if err == nil {
-		c.lastHash = content.Hash
+// osChangeToken returns the clipboard sequence number, which Windows
+// increments on every change to the clipboard.
+func osChangeToken() (uint64, bool) {
+	seq, _, _ := getClipboardSequenceNumber.Call()
+	if seq == 0 { // no WINSTA_ACCESSCLIPBOARD access
+		return 0, false
 	}
-	return err
+	return uint64(seq), true
 }
 
 func (c *Clipboard) writeText(data []byte) error {
@@ -143,10 +239,44 @@ func (c *Clipboard) writeText(data []byte) error {
 	return nil
 }
 
+// writeRTF sets the RTF document and a plain-text rendering of it, so apps
+// that cannot paste rich text still get the words.
+func (c *Clipboard) writeRTF(data []byte) error {
+	if cfRTF != 0 {
+		// CF_RTF is a NUL-terminated byte string.
+		if err := setFormat(cfRTF, append(append([]byte(nil), data...), 0)); err != nil {
+			return err
+		}
+	}
+	return c.writeText(rtfToText(data))
+}
+
+// writeHTML sets the HTML as CF_HTML and a plain-text rendering of it, so
+// apps that cannot paste rich text still get the words.
+func (c *Clipboard) writeHTML(data []byte) error {
+	if cfHTML != 0 {
+		// Like CF_RTF, CF_HTML is a NUL-terminated byte string.
+		if err := setFormat(cfHTML, append(encodeCFHTML(data), 0)); err != nil {
+			return err
+		}
+	}
+	return c.writeText(htmlToText(data))
+}
+
 func (c *Clipboard) writeImage(pngData []byte) error {
+	// Apps that paste transparency read CF_DIBV5 rather than PNG, so an
+	// image with alpha is offered that way too; Windows synthesizes CF_DIB
+	// from it for everything else.
+	alpha := pngHasAlpha(pngData)
+
 	// Try to set as PNG format first
 	if cfPNG != 0 {
 		if err := setFormat(cfPNG, pngData); err == nil {
+			if alpha {
+				if dibData, err := pngToDIB(pngData); err == nil {
+					setFormat(cfDIBV5, dibData) // best effort; the PNG is already set
+				}
+			}
 			return nil
 		}
 	}
@@ -156,6 +286,9 @@ func (c *Clipboard) writeImage(pngData []byte) error {
 	if err != nil {
 		return err
 	}
+	if alpha {
+		return setFormat(cfDIBV5, dibData)
+	}
 	return setFormat(cfDIB, dibData)
 }
 
@@ -237,310 +370,3 @@ func utf16ToUTF8(data []byte) []byte {
 
 	return []byte(string(utf16.Decode(u16)))
 }
-
-// DIB to PNG conversion - minimal implementation
-// DIB format: BITMAPINFOHEADER followed by pixel data
-func dibToPNG(dib []byte) ([]byte, error) {
-	if len(dib) < 40 {
-		return nil, errors.New("invalid DIB: too small")
-	}
-
-	// Parse BITMAPINFOHEADER
-	width := int32(binary.LittleEndian.Uint32(dib[4:8]))
-	height := int32(binary.LittleEndian.Uint32(dib[8:12]))
-	bitCount := binary.LittleEndian.Uint16(dib[14:16])
-
-	if width <= 0 || height == 0 {
-		return nil, errors.New("invalid DIB dimensions")
-	}
-
-	// Handle bottom-up (positive height) vs top-down (negative height)
-	bottomUp := height > 0
-	if height < 0 {
-		height = -height
-	}
-
-	if bitCount != 24 && bitCount != 32 {
-		return nil, fmt.Errorf("unsupported bit depth: %d", bitCount)
-	}
-
-	// Calculate row stride (rows are padded to 4-byte boundaries)
-	bytesPerPixel := int(bitCount) / 8
-	rowSize := ((int(width)*bytesPerPixel + 3) / 4) * 4
-	pixelOffset := 40 // After BITMAPINFOHEADER
-
-	if len(dib) < pixelOffset+rowSize*int(height) {
-		return nil, errors.New("invalid DIB: insufficient pixel data")
-	}
-
-	// Create PNG
-	var buf bytes.Buffer
-
-	// PNG signature
-	buf.Write([]byte{0x89, 'P', 'N', 'G', 0x0D, 0x0A, 0x1A, 0x0A})
-
-	// IHDR chunk
-	ihdr := make([]byte, 13)
-	binary.BigEndian.PutUint32(ihdr[0:4], uint32(width))
-	binary.BigEndian.PutUint32(ihdr[4:8], uint32(height))
-	ihdr[8] = 8  // bit depth
-	ihdr[9] = 2  // color type: RGB
-	ihdr[10] = 0 // compression
-	ihdr[11] = 0 // filter
-	ihdr[12] = 0 // interlace
-	writeChunk(&buf, "IHDR", ihdr)
-
-	// IDAT chunk - uncompressed for simplicity (zlib stored block)
-	var rawData bytes.Buffer
-	for y := 0; y < int(height); y++ {
-		srcY := y
-		if bottomUp {
-			srcY = int(height) - 1 - y
-		}
-		rowStart := pixelOffset + srcY*rowSize
-
-		rawData.WriteByte(0) // filter byte: none
-		for x := 0; x < int(width); x++ {
-			pixelStart := rowStart + x*bytesPerPixel
-			// DIB is BGR(A), PNG is RGB
-			rawData.WriteByte(dib[pixelStart+2]) // R
-			rawData.WriteByte(dib[pixelStart+1]) // G
-			rawData.WriteByte(dib[pixelStart+0]) // B
-		}
-	}
-
-	// Compress with zlib (deflate stored blocks for simplicity)
-	compressed := zlibCompress(rawData.Bytes())
-	writeChunk(&buf, "IDAT", compressed)
-
-	// IEND chunk
-	writeChunk(&buf, "IEND", nil)
-
-	return buf.Bytes(), nil
-}
-
-// PNG to DIB conversion
-func pngToDIB(png []byte) ([]byte, error) {
-	if len(png) < 8 || string(png[1:4]) != "PNG" {
-		return nil, errors.New("invalid PNG signature")
-	}
-
-	// Parse PNG chunks to find IHDR and IDAT
-	var width, height uint32
-	var bitDepth, colorType byte
-	var idatData []byte
-
-	pos := 8
-	for pos+8 <= len(png) {
-		chunkLen := binary.BigEndian.Uint32(png[pos:])
-		chunkType := string(png[pos+4 : pos+8])
-		chunkData := png[pos+8 : pos+8+int(chunkLen)]
-
-		switch chunkType {
-		case "IHDR":
-			if len(chunkData) >= 13 {
-				width = binary.BigEndian.Uint32(chunkData[0:4])
-				height = binary.BigEndian.Uint32(chunkData[4:8])
-				bitDepth = chunkData[8]
-				colorType = chunkData[9]
-			}
-		case "IDAT":
-			idatData = append(idatData, chunkData...)
-		case "IEND":
-			break
-		}
-		pos += 12 + int(chunkLen) // length + type + data + crc
-	}
-
-	if width == 0 || height == 0 {
-		return nil, errors.New("invalid PNG: missing IHDR")
-	}
-
-	if bitDepth != 8 || (colorType != 2 && colorType != 6) {
-		return nil, fmt.Errorf("unsupported PNG format: depth=%d type=%d", bitDepth, colorType)
-	}
-
-	// Decompress IDAT
-	rawData, err := zlibDecompress(idatData)
-	if err != nil {
-		return nil, fmt.Errorf("zlib decompress failed: %v", err)
-	}
-
-	// Calculate sizes
-	srcBytesPerPixel := 3
-	if colorType == 6 {
-		srcBytesPerPixel = 4 // RGBA
-	}
-	srcRowSize := 1 + int(width)*srcBytesPerPixel // +1 for filter byte
-
-	dstBytesPerPixel := 3 // 24-bit BGR
-	dstRowSize := ((int(width)*dstBytesPerPixel + 3) / 4) * 4
-
-	// Create DIB
-	dibSize := 40 + dstRowSize*int(height)
-	dib := make([]byte, dibSize)
-
-	// BITMAPINFOHEADER
-	binary.LittleEndian.PutUint32(dib[0:4], 40)          // biSize
-	binary.LittleEndian.PutUint32(dib[4:8], width)       // biWidth
-	binary.LittleEndian.PutUint32(dib[8:12], height)     // biHeight (positive = bottom-up)
-	binary.LittleEndian.PutUint16(dib[12:14], 1)         // biPlanes
-	binary.LittleEndian.PutUint16(dib[14:16], 24)        // biBitCount
-	binary.LittleEndian.PutUint32(dib[20:24], uint32(dstRowSize*int(height))) // biSizeImage
-
-	// Convert pixels (PNG is top-down, DIB is bottom-up)
-	for y := 0; y < int(height); y++ {
-		srcRow := y * srcRowSize
-		dstRow := 40 + (int(height)-1-y)*dstRowSize
-
-		if srcRow >= len(rawData) {
-			break
-		}
-
-		// Skip filter byte, apply no de-filtering (assumes filter=0)
-		for x := 0; x < int(width); x++ {
-			srcPixel := srcRow + 1 + x*srcBytesPerPixel
-			dstPixel := dstRow + x*dstBytesPerPixel
-
-			if srcPixel+2 < len(rawData) && dstPixel+2 < len(dib) {
-				// RGB -> BGR
-				dib[dstPixel+0] = rawData[srcPixel+2] // B
-				dib[dstPixel+1] = rawData[srcPixel+1] // G
-				dib[dstPixel+2] = rawData[srcPixel+0] // R
-			}
-		}
-	}
-
-	return dib, nil
-}
-
-func writeChunk(buf *bytes.Buffer, chunkType string, data []byte) {
-	var length [4]byte
-	binary.BigEndian.PutUint32(length[:], uint32(len(data)))
-	buf.Write(length[:])
-	buf.WriteString(chunkType)
-	buf.Write(data)
-
-	// CRC32 of type + data
-	crc := crc32(append([]byte(chunkType), data...))
-	var crcBytes [4]byte
-	binary.BigEndian.PutUint32(crcBytes[:], crc)
-	buf.Write(crcBytes[:])
-}
-
-// Minimal CRC32 for PNG
-func crc32(data []byte) uint32 {
-	var table [256]uint32
-	for i := 0; i < 256; i++ {
-		c := uint32(i)
-		for j := 0; j < 8; j++ {
-			if c&1 != 0 {
-				c = 0xEDB88320 ^ (c >> 1)
-			} else {
-				c >>= 1
-			}
-		}
-		table[i] = c
-	}
-
-	crc := uint32(0xFFFFFFFF)
-	for _, b := range data {
-		crc = table[(crc^uint32(b))&0xFF] ^ (crc >> 8)
-	}
-	return crc ^ 0xFFFFFFFF
-}
-
-// Minimal zlib compression using stored blocks (no actual compression)
-func zlibCompress(data []byte) []byte {
-	var buf bytes.Buffer
-
-	// Zlib header (no compression)
-	buf.WriteByte(0x78) // CMF: deflate, 32K window
-	buf.WriteByte(0x01) // FLG: no dict, fastest
-
-	// Split into stored blocks (max 65535 bytes each)
-	for len(data) > 0 {
-		blockSize := len(data)
-		if blockSize > 65535 {
-			blockSize = 65535
-		}
-		final := byte(0)
-		if blockSize == len(data) {
-			final = 1
-		}
-
-		buf.WriteByte(final)                                       // BFINAL + BTYPE=00 (stored)
-		buf.WriteByte(byte(blockSize))                             // LEN low
-		buf.WriteByte(byte(blockSize >> 8))                        // LEN high
-		buf.WriteByte(byte(^blockSize))                            // NLEN low
-		buf.WriteByte(byte((^blockSize) >> 8))                     // NLEN high
-		buf.Write(data[:blockSize])
-
-		data = data[blockSize:]
-	}
-
-	// Adler32 checksum
-	a := adler32(buf.Bytes()[2:]) // Skip zlib header
-	buf.WriteByte(byte(a >> 24))
-	buf.WriteByte(byte(a >> 16))
-	buf.WriteByte(byte(a >> 8))
-	buf.WriteByte(byte(a))
-
-	return buf.Bytes()
-}
-
-// Minimal zlib decompression (handles stored blocks)
-func zlibDecompress(data []byte) ([]byte, error) {
-	if len(data) < 6 {
-		return nil, errors.New("zlib data too short")
-	}
-
-	// Skip zlib header (2 bytes) and checksum (4 bytes at end)
-	deflate := data[2 : len(data)-4]
-
-	var result []byte
-	pos := 0
-
-	for pos < len(deflate) {
-		if pos >= len(deflate) {
-			break
-		}
-
-		header := deflate[pos]
-		btype := (header >> 1) & 3
-		pos++
-
-		if btype == 0 {
-			// Stored block
-			if pos+4 > len(deflate) {
-				return nil, errors.New("invalid stored block")
-			}
-			length := uint16(deflate[pos]) | (uint16(deflate[pos+1]) << 8)
-			pos += 4 // Skip LEN and NLEN
-
-			if pos+int(length) > len(deflate) {
-				return nil, errors.New("stored block exceeds data")
-			}
-			result = append(result, deflate[pos:pos+int(length)]...)
-			pos += int(length)
-		} else {
-			// Compressed blocks not supported in this minimal impl
-			return nil, fmt.Errorf("compressed deflate blocks not supported (type=%d)", btype)
-		}
-
-		if header&1 != 0 {
-			break // BFINAL
-		}
-	}
-
-	return result, nil
-}
-
-func adler32(data []byte) uint32 {
-	a, b := uint32(1), uint32(0)
-	for _, c := range data {
-		a = (a + uint32(c)) % 65521
-		b = (b + a) % 65521
-	}
-	return (b << 16) | a
-}
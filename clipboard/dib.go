@@ -0,0 +1,505 @@
+package clipboard
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DIB compression values from BITMAPINFOHEADER.biCompression.
+const (
+	biRGB       = 0
+	biBitfields = 3
+)
+
+const (
+	bitmapV5HeaderSize = 124
+	lcsSRGB            = 0x73524742 // 'sRGB', bV5CSType
+	lcsGMImages        = 4          // LCS_GM_IMAGES, bV5Intent
+)
+
+// DIB to PNG conversion - minimal implementation
+// DIB format: BITMAPINFOHEADER (or a larger V4/V5 header), optional color
+// masks and color table, then pixel data. Supports 1/4/8-bit palettized,
+// 16-bit (555, or any masks via BI_BITFIELDS), 24- and 32-bit images.
+func dibToPNG(dib []byte) ([]byte, error) {
+	if len(dib) < 40 {
+		return nil, errors.New("invalid DIB: too small")
+	}
+
+	// Parse BITMAPINFOHEADER
+	headerSize := int(binary.LittleEndian.Uint32(dib[0:4]))
+	width := int32(binary.LittleEndian.Uint32(dib[4:8]))
+	height := int32(binary.LittleEndian.Uint32(dib[8:12]))
+	bitCount := binary.LittleEndian.Uint16(dib[14:16])
+	compression := binary.LittleEndian.Uint32(dib[16:20])
+	clrUsed := int(binary.LittleEndian.Uint32(dib[32:36]))
+
+	if width <= 0 || height == 0 {
+		return nil, errors.New("invalid DIB dimensions")
+	}
+	if headerSize < 40 || headerSize > len(dib) {
+		return nil, errors.New("invalid DIB: bad header size")
+	}
+
+	// Handle bottom-up (positive height) vs top-down (negative height)
+	bottomUp := height > 0
+	if height < 0 {
+		height = -height
+	}
+
+	switch bitCount {
+	case 1, 4, 8, 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("unsupported bit depth: %d", bitCount)
+	}
+	if compression != biRGB && !(compression == biBitfields && (bitCount == 16 || bitCount == 32)) {
+		return nil, fmt.Errorf("unsupported DIB compression: %d", compression)
+	}
+
+	// The pixels follow the header, the R/G/B masks (which a plain
+	// BITMAPINFOHEADER stores after itself and larger headers embed), and
+	// the color table, which palettized images always have and others may
+	// carry as a hint (biClrUsed entries).
+	pixelOffset := headerSize
+	var masks [3]uint32
+	if compression == biBitfields {
+		if headerSize == 40 {
+			pixelOffset += 12
+		}
+		if len(dib) < 52 {
+			return nil, errors.New("invalid DIB: truncated header")
+		}
+		for i := range masks {
+			masks[i] = binary.LittleEndian.Uint32(dib[40+4*i:])
+		}
+	}
+	if bitCount <= 8 && clrUsed == 0 {
+		clrUsed = 1 << bitCount
+	}
+	if clrUsed > 1<<16 {
+		return nil, errors.New("invalid DIB: color table too large")
+	}
+	palette := dib[min(pixelOffset, len(dib)):min(pixelOffset+clrUsed*4, len(dib))]
+	pixelOffset += clrUsed * 4
+	if len(dib) < pixelOffset {
+		return nil, errors.New("invalid DIB: truncated color table")
+	}
+
+	// Alpha handling for 32-bit DIBs. BI_RGB has no declared alpha, but
+	// many apps put real alpha in the 4th byte anyway; BI_BITFIELDS carries
+	// it only when an alpha mask is present (V4/V5 headers).
+	var hasAlpha bool
+	switch {
+	case bitCount == 32 && compression == biRGB:
+		hasAlpha = true
+	case bitCount == 32 && compression == biBitfields:
+		if headerSize >= 56 {
+			hasAlpha = binary.LittleEndian.Uint32(dib[52:56]) != 0
+		}
+		if masks != [3]uint32{0x00FF0000, 0x0000FF00, 0x000000FF} {
+			return nil, fmt.Errorf("unsupported DIB color masks: %08x/%08x/%08x", masks[0], masks[1], masks[2])
+		}
+	case bitCount == 16 && compression == biRGB:
+		masks = [3]uint32{0x7C00, 0x03E0, 0x001F} // 5-5-5
+	}
+
+	// Calculate row stride (rows are padded to 4-byte boundaries)
+	rowSize := ((int(width)*int(bitCount) + 31) / 32) * 4
+
+	if len(dib) < pixelOffset+rowSize*int(height) {
+		return nil, errors.New("invalid DIB: insufficient pixel data")
+	}
+
+	// A 32-bit DIB whose alpha bytes are all zero is almost always an
+	// opaque image from an app that never filled alpha in; honoring it
+	// would paste as fully transparent.
+	if hasAlpha && dibAlphaAllZero(dib[pixelOffset:], int(width), int(height), rowSize) {
+		hasAlpha = false
+	}
+
+	var channels [3]maskChannel
+	for i, m := range masks {
+		channels[i] = newMaskChannel(m)
+	}
+
+	// Create PNG
+	var buf bytes.Buffer
+
+	// PNG signature
+	buf.Write([]byte{0x89, 'P', 'N', 'G', 0x0D, 0x0A, 0x1A, 0x0A})
+
+	// IHDR chunk
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:4], uint32(width))
+	binary.BigEndian.PutUint32(ihdr[4:8], uint32(height))
+	ihdr[8] = 8 // bit depth
+	ihdr[9] = 2 // color type: RGB
+	if hasAlpha {
+		ihdr[9] = 6 // color type: RGBA
+	}
+	ihdr[10] = 0 // compression
+	ihdr[11] = 0 // filter
+	ihdr[12] = 0 // interlace
+	writeChunk(&buf, "IHDR", ihdr)
+
+	// IDAT chunk - unfiltered scanlines
+	var rawData bytes.Buffer
+	for y := 0; y < int(height); y++ {
+		srcY := y
+		if bottomUp {
+			srcY = int(height) - 1 - y
+		}
+		row := dib[pixelOffset+srcY*rowSize:]
+
+		rawData.WriteByte(0) // filter byte: none
+		for x := 0; x < int(width); x++ {
+			switch bitCount {
+			case 1, 4, 8:
+				// Indices are packed most significant bits first; an index
+				// past the end of the table is black.
+				perByte := 8 / int(bitCount)
+				shift := (perByte - 1 - x%perByte) * int(bitCount)
+				idx := int(row[x/perByte]>>shift) & (1<<bitCount - 1)
+				var r, g, b byte
+				if idx*4+3 <= len(palette) {
+					b, g, r = palette[idx*4], palette[idx*4+1], palette[idx*4+2] // RGBQUAD
+				}
+				rawData.Write([]byte{r, g, b})
+			case 16:
+				px := uint32(binary.LittleEndian.Uint16(row[x*2:]))
+				rawData.Write([]byte{channels[0].value(px), channels[1].value(px), channels[2].value(px)})
+			default:
+				bytesPerPixel := int(bitCount) / 8
+				pixel := row[x*bytesPerPixel:]
+				// DIB is BGR(A), PNG is RGB(A)
+				rawData.WriteByte(pixel[2]) // R
+				rawData.WriteByte(pixel[1]) // G
+				rawData.WriteByte(pixel[0]) // B
+				if hasAlpha {
+					rawData.WriteByte(pixel[3]) // A
+				}
+			}
+		}
+	}
+
+	compressed := zlibCompress(rawData.Bytes())
+	writeChunk(&buf, "IDAT", compressed)
+
+	// IEND chunk
+	writeChunk(&buf, "IEND", nil)
+
+	return buf.Bytes(), nil
+}
+
+// maskChannel extracts one color channel from a pixel using a DIB color
+// mask, e.g. 0xF800 for the red of a 5-6-5 pixel.
+type maskChannel struct {
+	mask  uint32
+	shift int
+	max   uint32 // largest value of the channel once shifted down
+}
+
+func newMaskChannel(mask uint32) maskChannel {
+	c := maskChannel{mask: mask}
+	if mask == 0 {
+		return c
+	}
+	for mask&1 == 0 {
+		mask >>= 1
+		c.shift++
+	}
+	c.max = mask
+	return c
+}
+
+// value returns the channel of px scaled to 0-255.
+func (c maskChannel) value(px uint32) byte {
+	if c.max == 0 {
+		return 0
+	}
+	v := uint64((px & c.mask) >> c.shift)
+	return byte((v*255 + uint64(c.max)/2) / uint64(c.max))
+}
+
+// dibAlphaAllZero reports whether every alpha byte of a 32-bit pixel array
+// is zero.
+func dibAlphaAllZero(pixels []byte, width, height, rowSize int) bool {
+	for y := 0; y < height; y++ {
+		row := pixels[y*rowSize:]
+		for x := 0; x < width; x++ {
+			if row[x*4+3] != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// maxPNGPixels bounds the image size pngToDIB accepts (64 Mpx, e.g.
+// 8192x8192), so a crafted IHDR cannot request a multi-gigabyte DIB.
+const maxPNGPixels = 1 << 26
+
+// pngHasAlpha reports whether a PNG's IHDR declares an RGBA image, the
+// only kind pngToDIB converts to a DIB with alpha.
+func pngHasAlpha(png []byte) bool {
+	// Signature (8), IHDR length and type (8), width, height, bit depth.
+	return len(png) > 25 && string(png[12:16]) == "IHDR" && png[25] == 6
+}
+
+// PNG to DIB conversion
+func pngToDIB(png []byte) ([]byte, error) {
+	if len(png) < 8 || string(png[1:4]) != "PNG" {
+		return nil, errors.New("invalid PNG signature")
+	}
+
+	// Parse PNG chunks to find IHDR and IDAT
+	var width, height uint32
+	var bitDepth, colorType byte
+	var idatData []byte
+
+	pos := 8
+	for pos+8 <= len(png) {
+		chunkLen := binary.BigEndian.Uint32(png[pos:])
+		if uint64(pos)+12+uint64(chunkLen) > uint64(len(png)) { // length + type + data + crc
+			return nil, errors.New("invalid PNG: truncated chunk")
+		}
+		chunkType := string(png[pos+4 : pos+8])
+		chunkData := png[pos+8 : pos+8+int(chunkLen)]
+
+		switch chunkType {
+		case "IHDR":
+			if len(chunkData) >= 13 {
+				width = binary.BigEndian.Uint32(chunkData[0:4])
+				height = binary.BigEndian.Uint32(chunkData[4:8])
+				bitDepth = chunkData[8]
+				colorType = chunkData[9]
+			}
+		case "IDAT":
+			idatData = append(idatData, chunkData...)
+		case "IEND":
+			break
+		}
+		pos += 12 + int(chunkLen) // length + type + data + crc
+	}
+
+	if width == 0 || height == 0 {
+		return nil, errors.New("invalid PNG: missing IHDR")
+	}
+
+	if bitDepth != 8 || (colorType != 2 && colorType != 6) {
+		return nil, fmt.Errorf("unsupported PNG format: depth=%d type=%d", bitDepth, colorType)
+	}
+
+	if uint64(width)*uint64(height) > maxPNGPixels {
+		return nil, fmt.Errorf("PNG too large: %dx%d", width, height)
+	}
+
+	// Calculate sizes
+	srcBytesPerPixel := 3
+	if colorType == 6 {
+		srcBytesPerPixel = 4 // RGBA
+	}
+	srcRowSize := 1 + int(width)*srcBytesPerPixel // +1 for filter byte
+
+	// Decompress IDAT
+	rawData, err := zlibDecompress(idatData, srcRowSize*int(height))
+	if err != nil {
+		return nil, fmt.Errorf("zlib decompress failed: %v", err)
+	}
+	if err := unfilterPNG(rawData, srcRowSize, srcBytesPerPixel); err != nil {
+		return nil, err
+	}
+
+	// RGBA becomes a 32-bit BGRA DIB with a BITMAPV5HEADER (CF_DIBV5),
+	// whose alpha mask tells readers (and dibToPNG) the 4th byte is real,
+	// straight (not premultiplied) alpha.
+	hasAlpha := colorType == 6
+	headerSize := 40
+	dstBytesPerPixel := 3 // 24-bit BGR
+	if hasAlpha {
+		headerSize = bitmapV5HeaderSize
+		dstBytesPerPixel = 4
+	}
+	dstRowSize := ((int(width)*dstBytesPerPixel + 3) / 4) * 4
+
+	// Create DIB
+	dibSize := headerSize + dstRowSize*int(height)
+	dib := make([]byte, dibSize)
+
+	// BITMAPINFOHEADER
+	binary.LittleEndian.PutUint32(dib[0:4], uint32(headerSize))               // biSize
+	binary.LittleEndian.PutUint32(dib[4:8], width)                            // biWidth
+	binary.LittleEndian.PutUint32(dib[8:12], height)                          // biHeight (positive = bottom-up)
+	binary.LittleEndian.PutUint16(dib[12:14], 1)                              // biPlanes
+	binary.LittleEndian.PutUint16(dib[14:16], uint16(dstBytesPerPixel*8))     // biBitCount
+	binary.LittleEndian.PutUint32(dib[20:24], uint32(dstRowSize*int(height))) // biSizeImage
+	if hasAlpha {
+		binary.LittleEndian.PutUint32(dib[16:20], biBitfields)   // biCompression
+		binary.LittleEndian.PutUint32(dib[40:44], 0x00FF0000)    // bV5RedMask
+		binary.LittleEndian.PutUint32(dib[44:48], 0x0000FF00)    // bV5GreenMask
+		binary.LittleEndian.PutUint32(dib[48:52], 0x000000FF)    // bV5BlueMask
+		binary.LittleEndian.PutUint32(dib[52:56], 0xFF000000)    // bV5AlphaMask
+		binary.LittleEndian.PutUint32(dib[56:60], lcsSRGB)       // bV5CSType
+		binary.LittleEndian.PutUint32(dib[108:112], lcsGMImages) // bV5Intent
+	}
+
+	// Convert pixels (PNG is top-down, DIB is bottom-up)
+	for y := 0; y < int(height); y++ {
+		srcRow := y * srcRowSize
+		dstRow := headerSize + (int(height)-1-y)*dstRowSize
+
+		if srcRow >= len(rawData) {
+			break
+		}
+
+		// Skip filter byte; rows were reconstructed by unfilterPNG
+		for x := 0; x < int(width); x++ {
+			srcPixel := srcRow + 1 + x*srcBytesPerPixel
+			dstPixel := dstRow + x*dstBytesPerPixel
+
+			if srcPixel+srcBytesPerPixel <= len(rawData) {
+				// RGB(A) -> BGR(A)
+				dib[dstPixel+0] = rawData[srcPixel+2] // B
+				dib[dstPixel+1] = rawData[srcPixel+1] // G
+				dib[dstPixel+2] = rawData[srcPixel+0] // R
+				if hasAlpha {
+					dib[dstPixel+3] = rawData[srcPixel+3] // A
+				}
+			}
+		}
+	}
+
+	return dib, nil
+}
+
+// PNG scanline filter types (RFC 2083 section 6).
+const (
+	pngFilterNone    = 0
+	pngFilterSub     = 1
+	pngFilterUp      = 2
+	pngFilterAverage = 3
+	pngFilterPaeth   = 4
+)
+
+// unfilterPNG reverses the per-scanline filters in place. Each row is
+// rowSize bytes including its leading filter byte; bpp is bytes per pixel.
+// A trailing partial row is left untouched.
+func unfilterPNG(data []byte, rowSize, bpp int) error {
+	prev := make([]byte, rowSize-1) // the row above the first is all zero
+	for start := 0; start+rowSize <= len(data); start += rowSize {
+		filter, cur := data[start], data[start+1:start+rowSize]
+		switch filter {
+		case pngFilterNone:
+		case pngFilterSub:
+			for i := bpp; i < len(cur); i++ {
+				cur[i] += cur[i-bpp]
+			}
+		case pngFilterUp:
+			for i := range cur {
+				cur[i] += prev[i]
+			}
+		case pngFilterAverage:
+			for i := range cur {
+				var left byte
+				if i >= bpp {
+					left = cur[i-bpp]
+				}
+				cur[i] += byte((int(left) + int(prev[i])) / 2)
+			}
+		case pngFilterPaeth:
+			for i := range cur {
+				var left, upLeft byte
+				if i >= bpp {
+					left, upLeft = cur[i-bpp], prev[i-bpp]
+				}
+				cur[i] += paeth(left, prev[i], upLeft)
+			}
+		default:
+			return fmt.Errorf("invalid PNG filter type %d", filter)
+		}
+		prev = cur
+	}
+	return nil
+}
+
+// paeth is the Paeth predictor: whichever of left, up or upper-left is
+// closest to left + up - upLeft.
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	default:
+		return c
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func writeChunk(buf *bytes.Buffer, chunkType string, data []byte) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(data)))
+	buf.Write(length[:])
+	buf.WriteString(chunkType)
+	buf.Write(data)
+
+	// CRC32 of type + data
+	crc := crc32(append([]byte(chunkType), data...))
+	var crcBytes [4]byte
+	binary.BigEndian.PutUint32(crcBytes[:], crc)
+	buf.Write(crcBytes[:])
+}
+
+// Minimal CRC32 for PNG
+func crc32(data []byte) uint32 {
+	var table [256]uint32
+	for i := 0; i < 256; i++ {
+		c := uint32(i)
+		for j := 0; j < 8; j++ {
+			if c&1 != 0 {
+				c = 0xEDB88320 ^ (c >> 1)
+			} else {
+				c >>= 1
+			}
+		}
+		table[i] = c
+	}
+
+	crc := uint32(0xFFFFFFFF)
+	for _, b := range data {
+		crc = table[(crc^uint32(b))&0xFF] ^ (crc >> 8)
+	}
+	return crc ^ 0xFFFFFFFF
+}
+
+// zlibCompress wraps data in a zlib stream for a PNG IDAT chunk.
+func zlibCompress(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(data) // writes to a bytes.Buffer cannot fail
+	zw.Close()
+	return buf.Bytes()
+}
+
+// zlibDecompress inflates a PNG IDAT stream, reading at most limit bytes so
+// a crafted image cannot expand without bound.
+func zlibDecompress(data []byte, limit int) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(io.LimitReader(zr, int64(limit)))
+}
@@ -0,0 +1,480 @@
+package clipboard
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image/png"
+	"testing"
+)
+
+// makeDIB32 builds a bottom-up 32-bit DIB from top-down BGRA pixels. A
+// non-zero alphaMask emits a BITMAPV5HEADER with BI_BITFIELDS.
+func makeDIB32(width, height int, bgra [][4]byte, alphaMask uint32) []byte {
+	headerSize := 40
+	compression := uint32(biRGB)
+	if alphaMask != 0 {
+		headerSize = 124
+		compression = biBitfields
+	}
+	dib := make([]byte, headerSize+width*height*4)
+	binary.LittleEndian.PutUint32(dib[0:4], uint32(headerSize))
+	binary.LittleEndian.PutUint32(dib[4:8], uint32(width))
+	binary.LittleEndian.PutUint32(dib[8:12], uint32(height))
+	binary.LittleEndian.PutUint16(dib[12:14], 1)
+	binary.LittleEndian.PutUint16(dib[14:16], 32)
+	binary.LittleEndian.PutUint32(dib[16:20], compression)
+	if alphaMask != 0 {
+		binary.LittleEndian.PutUint32(dib[40:44], 0x00FF0000)
+		binary.LittleEndian.PutUint32(dib[44:48], 0x0000FF00)
+		binary.LittleEndian.PutUint32(dib[48:52], 0x000000FF)
+		binary.LittleEndian.PutUint32(dib[52:56], alphaMask)
+	}
+	for y := 0; y < height; y++ {
+		row := headerSize + (height-1-y)*width*4
+		for x := 0; x < width; x++ {
+			copy(dib[row+x*4:], bgra[y*width+x][:])
+		}
+	}
+	return dib
+}
+
+func decodePNG(t *testing.T, data []byte) [][4]uint8 {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode: %v", err)
+	}
+	b := img.Bounds()
+	var px [][4]uint8
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := img.At(x, y).RGBA()
+			px = append(px, [4]uint8{uint8(r >> 8), uint8(g >> 8), uint8(bl >> 8), uint8(a >> 8)})
+		}
+	}
+	return px
+}
+
+func TestDIBToPNG_ZeroAlphaIsOpaque(t *testing.T) {
+	dib := makeDIB32(2, 1, [][4]byte{{0x10, 0x20, 0x30, 0}, {0x40, 0x50, 0x60, 0}}, 0)
+
+	out, err := dibToPNG(dib)
+	if err != nil {
+		t.Fatalf("dibToPNG: %v", err)
+	}
+	got := decodePNG(t, out)
+	want := [][4]uint8{{0x30, 0x20, 0x10, 0xFF}, {0x60, 0x50, 0x40, 0xFF}}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("pixel %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDIBToPNG_PreservesAlpha(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		alphaMask uint32
+	}{
+		{"BI_RGB", 0},
+		{"BI_BITFIELDS", 0xFF000000},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// Opaque white over a fully transparent pixel.
+			dib := makeDIB32(2, 1, [][4]byte{{0xFF, 0xFF, 0xFF, 0xFF}, {0, 0, 0, 0}}, tc.alphaMask)
+
+			out, err := dibToPNG(dib)
+			if err != nil {
+				t.Fatalf("dibToPNG: %v", err)
+			}
+			got := decodePNG(t, out)
+			if got[0][3] != 0xFF || got[1][3] != 0 {
+				t.Errorf("alpha = %d,%d; want 255,0", got[0][3], got[1][3])
+			}
+		})
+	}
+}
+
+func TestDIBToPNG_BitfieldsWithoutAlphaMaskIsOpaque(t *testing.T) {
+	dib := makeDIB32(1, 1, [][4]byte{{0x10, 0x20, 0x30, 0x40}}, 0xFF000000)
+	binary.LittleEndian.PutUint32(dib[52:56], 0) // no alpha mask
+
+	out, err := dibToPNG(dib)
+	if err != nil {
+		t.Fatalf("dibToPNG: %v", err)
+	}
+	if got := decodePNG(t, out)[0]; got != [4]uint8{0x30, 0x20, 0x10, 0xFF} {
+		t.Errorf("pixel = %v, want opaque 302010", got)
+	}
+}
+
+// makePNG encodes scanlines with the given filter type and a real,
+// Huffman-compressed zlib stream.
+func makePNG(t *testing.T, width, height int, colorType, filter byte, rows [][]byte) []byte {
+	t.Helper()
+	bpp := 3
+	if colorType == 6 {
+		bpp = 4
+	}
+	var raw bytes.Buffer
+	prev := make([]byte, width*bpp)
+	for _, row := range rows {
+		raw.WriteByte(filter)
+		raw.Write(filterRow(filter, row, prev, bpp))
+		prev = row
+	}
+	var idat bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&idat, zlib.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw.Write(raw.Bytes())
+	zw.Close()
+
+	var buf bytes.Buffer
+	buf.Write([]byte{0x89, 'P', 'N', 'G', 0x0D, 0x0A, 0x1A, 0x0A})
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:4], uint32(width))
+	binary.BigEndian.PutUint32(ihdr[4:8], uint32(height))
+	ihdr[8] = 8
+	ihdr[9] = colorType
+	writeChunk(&buf, "IHDR", ihdr)
+	writeChunk(&buf, "IDAT", idat.Bytes())
+	writeChunk(&buf, "IEND", nil)
+	return buf.Bytes()
+}
+
+func TestPNGToDIB_CompressedIDAT(t *testing.T) {
+	// Repetitive rows so the encoder emits Huffman blocks, not stored ones.
+	const width, height = 64, 4
+	rows := make([][]byte, height)
+	for y := range rows {
+		rows[y] = bytes.Repeat([]byte{0x11, 0x22, byte(y)}, width)
+	}
+	src := makePNG(t, width, height, 2, pngFilterNone, rows)
+
+	dib, err := pngToDIB(src)
+	if err != nil {
+		t.Fatalf("pngToDIB: %v", err)
+	}
+	// Bottom-up: the last PNG row is the first DIB row.
+	if got := dib[40:43]; !bytes.Equal(got, []byte{3, 0x22, 0x11}) {
+		t.Errorf("first DIB pixel = %x, want 032211 (BGR of last row)", got)
+	}
+
+	out, err := dibToPNG(dib)
+	if err != nil {
+		t.Fatalf("dibToPNG: %v", err)
+	}
+	px := decodePNG(t, out)
+	for y := 0; y < height; y++ {
+		if got, want := px[y*width], [4]uint8{0x11, 0x22, uint8(y), 0xFF}; got != want {
+			t.Errorf("row %d pixel = %v, want %v", y, got, want)
+		}
+	}
+}
+
+func TestPNGToDIB_RejectsHugeDimensions(t *testing.T) {
+	src := makePNG(t, 1<<20, 1<<20, 2, pngFilterNone, nil)
+	if _, err := pngToDIB(src); err == nil {
+		t.Error("expected error for oversized PNG, got nil")
+	}
+}
+
+// filterRow is the encoder side of unfilterPNG.
+func filterRow(filter byte, cur, prev []byte, bpp int) []byte {
+	out := make([]byte, len(cur))
+	for i := range cur {
+		var left, upLeft byte
+		if i >= bpp {
+			left, upLeft = cur[i-bpp], prev[i-bpp]
+		}
+		switch filter {
+		case pngFilterNone:
+			out[i] = cur[i]
+		case pngFilterSub:
+			out[i] = cur[i] - left
+		case pngFilterUp:
+			out[i] = cur[i] - prev[i]
+		case pngFilterAverage:
+			out[i] = cur[i] - byte((int(left)+int(prev[i]))/2)
+		case pngFilterPaeth:
+			out[i] = cur[i] - paeth(left, prev[i], upLeft)
+		}
+	}
+	return out
+}
+
+func TestPNGToDIB_Filters(t *testing.T) {
+	// A 3x3 gradient: values vary along both axes so every predictor
+	// (left, up, average, Paeth) contributes.
+	const width, height = 3, 3
+	rows := make([][]byte, height)
+	for y := range rows {
+		for x := 0; x < width; x++ {
+			rows[y] = append(rows[y], byte(10+40*x+7*y), byte(200-30*y+x), byte(90*x*y))
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		filter byte
+	}{
+		{"None", pngFilterNone},
+		{"Sub", pngFilterSub},
+		{"Up", pngFilterUp},
+		{"Average", pngFilterAverage},
+		{"Paeth", pngFilterPaeth},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dib, err := pngToDIB(makePNG(t, width, height, 2, tc.filter, rows))
+			if err != nil {
+				t.Fatalf("pngToDIB: %v", err)
+			}
+			rowSize := (width*3 + 3) / 4 * 4
+			for y := 0; y < height; y++ {
+				dibRow := dib[40+(height-1-y)*rowSize:]
+				for x := 0; x < width; x++ {
+					got := dibRow[x*3 : x*3+3]
+					r, g, b := rows[y][x*3], rows[y][x*3+1], rows[y][x*3+2]
+					if got[0] != b || got[1] != g || got[2] != r {
+						t.Errorf("(%d,%d) BGR = %v, want [%d %d %d]", x, y, got, b, g, r)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPNGToDIB_InvalidFilter(t *testing.T) {
+	if _, err := pngToDIB(makePNG(t, 1, 1, 2, 5, [][]byte{{1, 2, 3}})); err == nil {
+		t.Error("expected error for filter type 5, got nil")
+	}
+}
+
+func TestPNGToDIB_AlphaRoundTrip(t *testing.T) {
+	// Semi-transparent red next to opaque blue.
+	rows := [][]byte{{0xFF, 0, 0, 0x80, 0, 0, 0xFF, 0xFF}}
+	dib, err := pngToDIB(makePNG(t, 2, 1, 6, pngFilterNone, rows))
+	if err != nil {
+		t.Fatalf("pngToDIB: %v", err)
+	}
+	if bits := binary.LittleEndian.Uint16(dib[14:16]); bits != 32 {
+		t.Fatalf("biBitCount = %d, want 32", bits)
+	}
+	if got := dib[bitmapV5HeaderSize : bitmapV5HeaderSize+4]; !bytes.Equal(got, []byte{0, 0, 0xFF, 0x80}) {
+		t.Errorf("first DIB pixel BGRA = %x, want 0000ff80", got)
+	}
+
+	out, err := dibToPNG(dib)
+	if err != nil {
+		t.Fatalf("dibToPNG: %v", err)
+	}
+	px := decodePNG(t, out)
+	// image/png returns premultiplied colors; alpha is exact.
+	if px[0][3] != 0x80 || px[1][3] != 0xFF {
+		t.Errorf("alpha = %d,%d; want 128,255", px[0][3], px[1][3])
+	}
+	if px[1] != [4]uint8{0, 0, 0xFF, 0xFF} {
+		t.Errorf("opaque pixel = %v, want blue", px[1])
+	}
+}
+
+// makeDIB builds a bottom-up DIB with the given header size, compression,
+// trailing data (masks and/or color table) and top-down packed rows, each
+// padded here to 4 bytes.
+func makeDIB(width, height, headerSize int, bitCount uint16, compression uint32, clrUsed int, extra []byte, rows [][]byte) []byte {
+	rowSize := ((width*int(bitCount) + 31) / 32) * 4
+	dib := make([]byte, headerSize, headerSize+len(extra)+rowSize*height)
+	binary.LittleEndian.PutUint32(dib[0:4], uint32(headerSize))
+	binary.LittleEndian.PutUint32(dib[4:8], uint32(width))
+	binary.LittleEndian.PutUint32(dib[8:12], uint32(height))
+	binary.LittleEndian.PutUint16(dib[12:14], 1)
+	binary.LittleEndian.PutUint16(dib[14:16], bitCount)
+	binary.LittleEndian.PutUint32(dib[16:20], compression)
+	binary.LittleEndian.PutUint32(dib[32:36], uint32(clrUsed))
+	dib = append(dib, extra...)
+	for y := height - 1; y >= 0; y-- {
+		row := make([]byte, rowSize)
+		copy(row, rows[y])
+		dib = append(dib, row...)
+	}
+	return dib
+}
+
+func le16(vs ...uint16) []byte {
+	var b []byte
+	for _, v := range vs {
+		b = binary.LittleEndian.AppendUint16(b, v)
+	}
+	return b
+}
+
+func le32(vs ...uint32) []byte {
+	var b []byte
+	for _, v := range vs {
+		b = binary.LittleEndian.AppendUint32(b, v)
+	}
+	return b
+}
+
+func TestDIBToPNG_Depths(t *testing.T) {
+	red, green, blue, white := [4]uint8{255, 0, 0, 255}, [4]uint8{0, 255, 0, 255}, [4]uint8{0, 0, 255, 255}, [4]uint8{255, 255, 255, 255}
+	// RGBQUADs: blue, green, red, reserved.
+	palette := []byte{0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 0, 255, 255, 255, 0}
+
+	tests := []struct {
+		name string
+		dib  []byte
+		want [][4]uint8
+	}{
+		{
+			name: "8-bit palette with biClrUsed",
+			dib:  makeDIB(3, 2, 40, 8, biRGB, 4, palette, [][]byte{{0, 1, 2}, {3, 0, 9}}),
+			want: [][4]uint8{red, green, blue, white, red, {0, 0, 0, 255}}, // 9 is past the table
+		},
+		{
+			name: "8-bit full palette",
+			dib:  makeDIB(2, 1, 40, 8, biRGB, 0, append(append([]byte(nil), palette...), make([]byte, 252*4)...), [][]byte{{3, 2}}),
+			want: [][4]uint8{white, blue},
+		},
+		{
+			name: "4-bit",
+			dib:  makeDIB(3, 1, 40, 4, biRGB, 4, palette, [][]byte{{0x12, 0x30}}),
+			want: [][4]uint8{green, blue, white},
+		},
+		{
+			name: "1-bit",
+			dib:  makeDIB(10, 1, 40, 1, biRGB, 2, palette[8:], [][]byte{{0b10100000, 0b01000000}}),
+			want: [][4]uint8{white, blue, white, blue, blue, blue, blue, blue, blue, white},
+		},
+		{
+			name: "16-bit 555",
+			dib:  makeDIB(4, 1, 40, 16, biRGB, 0, nil, [][]byte{le16(0x7C00, 0x03E0, 0x001F, 0x7FFF)}),
+			want: [][4]uint8{red, green, blue, white},
+		},
+		{
+			name: "16-bit 565 bitfields",
+			dib:  makeDIB(4, 1, 40, 16, biBitfields, 0, le32(0xF800, 0x07E0, 0x001F), [][]byte{le16(0xF800, 0x07E0, 0x001F, 0x8410)}),
+			want: [][4]uint8{red, green, blue, {132, 130, 132, 255}}, // mid grey
+		},
+		{
+			name: "16-bit 565 in a V5 header",
+			dib: func() []byte {
+				d := makeDIB(1, 1, 124, 16, biBitfields, 0, nil, [][]byte{le16(0x07E0)})
+				copy(d[40:], le32(0xF800, 0x07E0, 0x001F))
+				return d
+			}(),
+			want: [][4]uint8{green},
+		},
+		{
+			name: "24-bit in a V5 header",
+			dib:  makeDIB(2, 1, 124, 24, biRGB, 0, nil, [][]byte{{0, 0, 255, 255, 0, 0}}),
+			want: [][4]uint8{red, blue},
+		},
+		{
+			name: "24-bit with a color table hint",
+			dib:  makeDIB(1, 1, 40, 24, biRGB, 2, palette[:8], [][]byte{{0, 255, 0}}),
+			want: [][4]uint8{green},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := dibToPNG(tc.dib)
+			if err != nil {
+				t.Fatalf("dibToPNG: %v", err)
+			}
+			got := decodePNG(t, out)
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %d pixels, want %d", len(got), len(tc.want))
+			}
+			for i := range tc.want {
+				if got[i] != tc.want[i] {
+					t.Errorf("pixel %d = %v, want %v", i, got[i], tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDIBToPNG_TruncatedColorTable(t *testing.T) {
+	dib := makeDIB(1, 1, 40, 8, biRGB, 0, nil, [][]byte{{0}})
+	if _, err := dibToPNG(dib); err == nil {
+		t.Error("expected error for an 8-bit DIB without its color table")
+	}
+}
+
+func TestDIBToPNG_PixelOffsetFromHeader(t *testing.T) {
+	// A 32-bit BI_RGB DIB with a two-entry color table hint: with a
+	// BITMAPV4HEADER the pixels start at 108 + 8, not at 40.
+	for _, headerSize := range []int{40, 108, bitmapV5HeaderSize} {
+		dib := makeDIB(1, 1, headerSize, 32, biRGB, 2, []byte{1, 2, 3, 4, 5, 6, 7, 8}, [][]byte{{0x10, 0x20, 0x30, 0xFF}})
+		out, err := dibToPNG(dib)
+		if err != nil {
+			t.Fatalf("header %d: dibToPNG: %v", headerSize, err)
+		}
+		if got := decodePNG(t, out)[0]; got != [4]uint8{0x30, 0x20, 0x10, 0xFF} {
+			t.Errorf("header %d: pixel = %v, want 302010ff", headerSize, got)
+		}
+	}
+}
+
+func TestPNGToDIB_HeaderSizeMatchesPixelOffset(t *testing.T) {
+	// pngToDIB writes a V5 header for RGBA; reading it back must find the
+	// pixels right after it.
+	png := makePNG(t, 1, 1, 6, pngFilterNone, [][]byte{{0x30, 0x20, 0x10, 0x80}})
+	dib, err := pngToDIB(png)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := binary.LittleEndian.Uint32(dib[0:4]); size != bitmapV5HeaderSize || len(dib) != bitmapV5HeaderSize+4 {
+		t.Fatalf("biSize = %d, len = %d; want pixels right after a %d-byte header", size, len(dib), bitmapV5HeaderSize)
+	}
+	if !bytes.Equal(dib[bitmapV5HeaderSize:], []byte{0x10, 0x20, 0x30, 0x80}) {
+		t.Errorf("pixel = % x, want BGRA 10 20 30 80", dib[bitmapV5HeaderSize:])
+	}
+}
+
+func TestPNGHasAlpha(t *testing.T) {
+	rgba := makePNG(t, 1, 1, 6, pngFilterNone, [][]byte{{1, 2, 3, 4}})
+	rgb := makePNG(t, 1, 1, 2, pngFilterNone, [][]byte{{1, 2, 3}})
+	if !pngHasAlpha(rgba) {
+		t.Error("RGBA PNG: pngHasAlpha = false")
+	}
+	if pngHasAlpha(rgb) || pngHasAlpha(rgb[:20]) || pngHasAlpha(nil) {
+		t.Error("pngHasAlpha = true for an RGB or truncated PNG")
+	}
+}
+
+func TestDIBToPNG_ReadsV5FromPNGToDIB(t *testing.T) {
+	// What writeImage puts on the clipboard as CF_DIBV5 must read back with
+	// its alpha.
+	png := makePNG(t, 2, 1, 6, pngFilterNone, [][]byte{{0xFF, 0, 0, 0x80, 0, 0xFF, 0, 0}})
+	dib, err := pngToDIB(png)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := dibToPNG(dib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := decodePNG(t, out)
+	// decodePNG returns premultiplied color, so half-transparent red reads
+	// as 80 00 00 80.
+	if got[0] != [4]uint8{0x80, 0, 0, 0x80} || got[1][3] != 0 {
+		t.Errorf("pixels = %v, want half-transparent red then transparent", got)
+	}
+}
+
+func TestPNGToDIB_TruncatedChunk(t *testing.T) {
+	full := makePNG(t, 4, 4, 2, pngFilterNone, [][]byte{make([]byte, 12), make([]byte, 12), make([]byte, 12), make([]byte, 12)})
+	for _, n := range []int{20, 33, len(full) - 20, len(full) - 1} {
+		// Cap the capacity so slicing past the end panics instead of
+		// reading the rest of the original buffer.
+		truncated := full[:n:n]
+		if _, err := pngToDIB(truncated); err == nil {
+			t.Errorf("pngToDIB(first %d of %d bytes): expected error", n, len(full))
+		}
+	}
+}
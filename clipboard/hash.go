@@ -0,0 +1,69 @@
+package clipboard
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/maphash"
+	"sync/atomic"
+)
+
+// Hash algorithm names accepted by SetHashAlgorithm.
+const (
+	HashSHA256  = "sha256"
+	HashMaphash = "maphash"
+)
+
+// hashFunc holds the active content-identity function. It is process-wide:
+// hashes never leave the node, so nodes using different algorithms still
+// interoperate.
+var hashFunc atomic.Pointer[func([]byte) string]
+
+func init() {
+	f := sha256Hex
+	hashFunc.Store(&f)
+}
+
+// SetHashAlgorithm selects the function used by HashData. SHA-256 is the
+// default; maphash is several times faster on large images (and far faster
+// on CPUs without SHA extensions) and is adequate for local change
+// detection, which does not need collision resistance against an adversary.
+// Its seed is random per process, so maphash digests must never be persisted
+// or compared across processes. An empty name selects the default.
+func SetHashAlgorithm(name string) error {
+	var f func([]byte) string
+	switch name {
+	case "", HashSHA256:
+		f = sha256Hex
+	case HashMaphash:
+		f = maphashHex
+	default:
+		return fmt.Errorf("unknown hash algorithm %q (want %s or %s)", name, HashSHA256, HashMaphash)
+	}
+	hashFunc.Store(&f)
+	return nil
+}
+
+// HashData returns the hex digest used as Content.Hash. It is the single
+// content-identity function for paperclip: the poller, the relay receiver,
+// and anything else that compares clipboard hashes must use it so hashes
+// computed for the same payload agree.
+func HashData(data []byte) string {
+	return (*hashFunc.Load())(data)
+}
+
+func sha256Hex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// maphashSeed is fixed for the life of the process so digests are stable
+// between polls.
+var maphashSeed = maphash.MakeSeed()
+
+func maphashHex(data []byte) string {
+	var sum [8]byte
+	binary.BigEndian.PutUint64(sum[:], maphash.Bytes(maphashSeed, data))
+	return hex.EncodeToString(sum[:])
+}
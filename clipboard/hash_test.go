@@ -0,0 +1,60 @@
+package clipboard
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestHasChangedUnderEachHash(t *testing.T) {
+	defer SetHashAlgorithm(HashSHA256)
+
+	for _, algo := range []string{HashSHA256, HashMaphash} {
+		t.Run(algo, func(t *testing.T) {
+			if err := SetHashAlgorithm(algo); err != nil {
+				t.Fatalf("SetHashAlgorithm: %v", err)
+			}
+			c := New(log.New(os.Stderr, "[test] ", 0))
+
+			first := HashData([]byte("first"))
+			if !c.HasChanged(first) {
+				t.Error("expected change from empty lastHash")
+			}
+			c.SetLastHash(first)
+			if c.HasChanged(HashData([]byte("first"))) {
+				t.Error("same content reported as changed")
+			}
+			if !c.HasChanged(HashData([]byte("second"))) {
+				t.Error("different content reported as unchanged")
+			}
+		})
+	}
+}
+
+func TestSetHashAlgorithmUnknown(t *testing.T) {
+	if err := SetHashAlgorithm("md5"); err == nil {
+		t.Error("expected error for unknown algorithm")
+	}
+	if got := len(HashData([]byte("x"))); got != 64 {
+		t.Errorf("failed SetHashAlgorithm should leave SHA-256 active, got %d-char digest", got)
+	}
+}
+
+func BenchmarkHashData(b *testing.B) {
+	defer SetHashAlgorithm(HashSHA256)
+
+	for _, size := range []int{4 << 10, 4 << 20} {
+		data := bytes.Repeat([]byte{0xA5}, size)
+		for _, algo := range []string{HashSHA256, HashMaphash} {
+			b.Run(fmt.Sprintf("%s/%dKB", algo, size>>10), func(b *testing.B) {
+				SetHashAlgorithm(algo)
+				b.SetBytes(int64(size))
+				for i := 0; i < b.N; i++ {
+					HashData(data)
+				}
+			})
+		}
+	}
+}
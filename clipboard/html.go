@@ -0,0 +1,81 @@
+package clipboard
+
+import (
+	"html"
+	"strings"
+)
+
+// htmlBlockTags end a line when converting HTML to plain text.
+var htmlBlockTags = map[string]bool{
+	"br": true, "p": true, "div": true, "li": true, "tr": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"pre": true, "blockquote": true, "table": true, "ul": true, "ol": true,
+}
+
+// htmlToText returns a readable plain-text rendering of HTML markup, for
+// platforms that cannot put HTML on the clipboard. Tags are dropped, block
+// elements become line breaks, script and style bodies are skipped and
+// entities are decoded. It is not a full HTML parser.
+func htmlToText(markup []byte) []byte {
+	s := string(markup)
+	var b strings.Builder
+	skip := "" // closing tag whose body is being skipped, e.g. "/script"
+	for len(s) > 0 {
+		lt := strings.IndexByte(s, '<')
+		if lt < 0 {
+			if skip == "" {
+				b.WriteString(s)
+			}
+			break
+		}
+		if skip == "" {
+			b.WriteString(s[:lt])
+		}
+		s = s[lt:]
+
+		if strings.HasPrefix(s, "<!--") {
+			end := strings.Index(s, "-->")
+			if end < 0 {
+				break
+			}
+			s = s[end+len("-->"):]
+			continue
+		}
+		gt := strings.IndexByte(s, '>')
+		if gt < 0 {
+			break
+		}
+		name := htmlTagName(s[1:gt])
+		s = s[gt+1:]
+
+		switch {
+		case skip != "":
+			if name == skip {
+				skip = ""
+			}
+		case name == "script" || name == "style" || name == "head":
+			skip = "/" + name
+		case htmlBlockTags[strings.TrimPrefix(name, "/")]:
+			if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+				b.WriteByte('\n')
+			}
+		}
+	}
+	return []byte(strings.TrimSpace(html.UnescapeString(b.String())))
+}
+
+// htmlTagName returns the lower-cased name of a tag body such as
+// `a href="x"` or `/p`, keeping a leading slash for closing tags.
+func htmlTagName(tag string) string {
+	end := strings.IndexAny(tag, " \t\r\n/>")
+	if strings.HasPrefix(tag, "/") {
+		end = strings.IndexAny(tag[1:], " \t\r\n>")
+		if end >= 0 {
+			end++
+		}
+	}
+	if end < 0 {
+		end = len(tag)
+	}
+	return strings.ToLower(tag[:end])
+}
@@ -0,0 +1,25 @@
+package clipboard
+
+import "testing"
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"plain", "hello", "hello"},
+		{"inline tags", `<b>bold</b> and <a href="x">link</a>`, "bold and link"},
+		{"entities", "fish &amp; chips &lt;3", "fish & chips <3"},
+		{"blocks", "<p>one</p><p>two</p>", "one\ntwo"},
+		{"br", "a<br>b<br/>c", "a\nb\nc"},
+		{"list", "<ul><li>x</li><li>y</li></ul>", "x\ny"},
+		{"script and style", "<style>p{}</style>keep<script>drop()</script>", "keep"},
+		{"comment", "a<!-- <p>hidden</p> -->b", "ab"},
+		{"fragment", "<html><head><title>t</title></head><body><!--StartFragment--><i>x</i><!--EndFragment--></body></html>", "x"},
+		{"upper case", "<P>A</P><BR>B", "A\nB"},
+	}
+	for _, tc := range tests {
+		if got := string(htmlToText([]byte(tc.in))); got != tc.want {
+			t.Errorf("%s: htmlToText(%q) = %q, want %q", tc.name, tc.in, got, tc.want)
+		}
+	}
+}
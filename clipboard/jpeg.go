@@ -0,0 +1,80 @@
+package clipboard
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+)
+
+// photoSampleGrid is how many points per side looksPhotographic samples.
+const photoSampleGrid = 128
+
+// PhotoToJPEG re-encodes a PNG as JPEG at quality (1-100) if it looks like
+// a photograph. Graphics such as screenshots of windows and text stay PNG,
+// since JPEG blurs their edges, and so do images with transparency, which
+// JPEG cannot hold. ok is false whenever the PNG should be sent as is,
+// including when the JPEG would not be smaller.
+func PhotoToJPEG(data []byte, quality int) (out []byte, ok bool) {
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil || uint64(cfg.Width)*uint64(cfg.Height) > maxPNGPixels {
+		return nil, false
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, false
+	}
+	if o, isOpaquer := img.(interface{ Opaque() bool }); isOpaquer && !o.Opaque() {
+		return nil, false
+	}
+	if !looksPhotographic(img) {
+		return nil, false
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil || buf.Len() >= len(data) {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+// looksPhotographic samples img on a grid and reports whether at least a
+// quarter of the samples have distinct colors. Photographs, with their
+// gradients and sensor noise, have nearly as many colors as pixels; UI
+// screenshots and diagrams reuse a small palette.
+func looksPhotographic(img image.Image) bool {
+	b := img.Bounds()
+	stepX, stepY := max(1, b.Dx()/photoSampleGrid), max(1, b.Dy()/photoSampleGrid)
+	seen := make(map[[3]uint32]struct{})
+	samples := 0
+	for y := b.Min.Y; y < b.Max.Y; y += stepY {
+		for x := b.Min.X; x < b.Max.X; x += stepX {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			seen[[3]uint32{r, g, bl}] = struct{}{}
+			samples++
+		}
+	}
+	return len(seen)*4 >= samples
+}
+
+// JPEGToPNG decodes a JPEG and re-encodes it as PNG, the image format the
+// clipboard backends write.
+func JPEGToPNG(data []byte) ([]byte, error) {
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err // already reads "invalid JPEG format: ..."
+	}
+	if uint64(cfg.Width)*uint64(cfg.Height) > maxPNGPixels {
+		return nil, fmt.Errorf("JPEG too large (%dx%d)", cfg.Width, cfg.Height)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
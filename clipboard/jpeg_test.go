@@ -0,0 +1,87 @@
+package clipboard
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"testing"
+)
+
+// photoImage returns an opaque n×n gradient with a little noise, which
+// behaves like a photograph: many colors, poor PNG compression.
+func photoImage(n int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, n, n))
+	rnd := rand.New(rand.NewSource(1))
+	jitter := func(v int) uint8 { return uint8(max(0, min(255, v+rnd.Intn(9)-4))) }
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			img.SetRGBA(x, y, color.RGBA{jitter(x * 255 / n), jitter(y * 255 / n), jitter((x + y) * 255 / (2 * n)), 255})
+		}
+	}
+	return img
+}
+
+func TestPhotoToJPEG_Photo(t *testing.T) {
+	src := encodeTestPNG(t, photoImage(192))
+	out, ok := PhotoToJPEG(src, 80)
+	if !ok {
+		t.Fatal("photographic image was not converted")
+	}
+	if len(out) >= len(src) {
+		t.Errorf("JPEG is %d bytes, PNG %d; want smaller", len(out), len(src))
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(out)); err != nil {
+		t.Errorf("output is not a JPEG: %v", err)
+	}
+}
+
+func TestPhotoToJPEG_KeepsPNG(t *testing.T) {
+	// A UI-like image: flat background with a few colored blocks.
+	graphic := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	for y := 0; y < 256; y++ {
+		for x := 0; x < 256; x++ {
+			c := color.RGBA{0xF0, 0xF0, 0xF0, 0xFF}
+			if x/32%2 == 0 && y/32%3 == 0 {
+				c = color.RGBA{0x20, 0x60, 0xC0, 0xFF}
+			}
+			graphic.SetRGBA(x, y, c)
+		}
+	}
+
+	transparent := photoImage(192)
+	transparent.SetRGBA(0, 0, color.RGBA{})
+
+	for name, img := range map[string]image.Image{"graphic": graphic, "transparent": transparent} {
+		if _, ok := PhotoToJPEG(encodeTestPNG(t, img), 80); ok {
+			t.Errorf("%s: converted to JPEG, want PNG kept", name)
+		}
+	}
+	if _, ok := PhotoToJPEG([]byte("not a png"), 80); ok {
+		t.Error("invalid PNG: converted to JPEG")
+	}
+}
+
+func TestJPEGToPNG(t *testing.T) {
+	var j bytes.Buffer
+	if err := jpeg.Encode(&j, photoImage(64), &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := JPEGToPNG(j.Bytes())
+	if err != nil {
+		t.Fatalf("JPEGToPNG: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("output is not a PNG: %v", err)
+	}
+	if got := img.Bounds().Size(); got != (image.Point{64, 64}) {
+		t.Errorf("size = %v, want 64x64", got)
+	}
+
+	if _, err := JPEGToPNG([]byte("not a jpeg")); err == nil {
+		t.Error("expected error for invalid JPEG")
+	}
+}
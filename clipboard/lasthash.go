@@ -0,0 +1,103 @@
+package clipboard
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// hashKeySize is the length of the per-install key for persisted digests.
+const hashKeySize = 32
+
+// PersistLastHash loads the last synced hash from path, if the file exists,
+// and saves later syncs to it: content written by Write and content
+// reported with MarkSent. A restart then does not treat whatever is on the
+// clipboard as a new copy and broadcast it again.
+//
+// The file holds an HMAC-SHA256 of the hash under a random key kept in
+// path+".key", created on first use, so it cannot be used to confirm a
+// guess at what was copied. Content that was never synced, such as
+// concealed or excluded items, is not recorded.
+//
+// Only SHA-256 hashes are stable across processes, so this must not be used
+// with the maphash algorithm.
+func (c *Clipboard) PersistLastHash(path string) error {
+	key, err := loadHashKey(path + ".key")
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hashFile = path
+	c.hashKey = key
+	c.savedDigest = strings.TrimSpace(string(data))
+	return nil
+}
+
+// loadHashKey reads the digest key from path, creating it if missing.
+func loadHashKey(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if err == nil {
+		if len(key) != hashKeySize {
+			return nil, fmt.Errorf("%s: want a %d-byte key, got %d bytes", path, hashKeySize, len(key))
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	key = make([]byte, hashKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, key, 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// MarkSent records hash as the last-seen content and, if persistence is
+// enabled, saves it. Call it once content has actually been published.
+func (c *Clipboard) MarkSent(hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastHash = hash
+	c.saveHashLocked(hash)
+}
+
+// savedHashLocked reports whether hash is the one persisted by a previous
+// run. c.mu must be held.
+func (c *Clipboard) savedHashLocked(hash string) bool {
+	return c.savedDigest != "" && hmac.Equal([]byte(c.digestLocked(hash)), []byte(c.savedDigest))
+}
+
+// saveHashLocked persists hash if enabled. c.mu must be held.
+func (c *Clipboard) saveHashLocked(hash string) {
+	if c.hashFile == "" {
+		return
+	}
+	digest := c.digestLocked(hash)
+	if digest == c.savedDigest {
+		return
+	}
+	c.savedDigest = digest
+	if err := os.WriteFile(c.hashFile, []byte(digest+"\n"), 0600); err != nil && c.logger != nil {
+		c.logger.Printf("WARNING: could not save last clipboard hash: %v", err)
+	}
+}
+
+func (c *Clipboard) digestLocked(hash string) string {
+	mac := hmac.New(sha256.New, c.hashKey)
+	mac.Write([]byte(hash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
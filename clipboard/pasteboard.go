@@ -0,0 +1,182 @@
+package clipboard
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// Pasteboard type identifiers (UTIs) understood by the macOS backend.
+const (
+	UTIPNG       = "public.png"
+	UTITIFF      = "public.tiff"
+	UTIPlainText = "public.utf8-plain-text"
+	UTIHTML      = "public.html"
+	UTIRTF       = "public.rtf"
+
+	// UTIConcealed marks pasteboard content as a secret, e.g. a password
+	// copied from a password manager (see nspasteboard.org).
+	UTIConcealed = "org.nspasteboard.ConcealedType"
+)
+
+// DefaultPasteboardTypes is the macOS read priority used when none is
+// configured: images first, then plain text. HTML and RTF are opt-in: a
+// receiver without support for them writes the raw markup to its clipboard
+// as text.
+var DefaultPasteboardTypes = []string{UTIPNG, UTITIFF, UTIPlainText}
+
+// pasteboardContentTypes maps each readable pasteboard type to the content
+// type it is synced as. Adding a type here makes it selectable in the
+// configured priority list.
+var pasteboardContentTypes = map[string]ContentType{
+	UTIPNG:       TypeImage,
+	UTITIFF:      TypeImage, // converted to PNG by the read script
+	UTIPlainText: TypeText,
+	UTIHTML:      TypeHTML,
+	UTIRTF:       TypeRTF,
+}
+
+// maxImageBytes caps the clipboard image size we will accept (16 MB).
+// Images larger than this are silently ignored to prevent OOM during
+// TIFF→PNG conversion of arbitrarily large clipboard contents.
+const maxImageBytes = 16 * 1024 * 1024
+
+// runOSAScript runs an AppleScript and returns its stdout. It is a variable
+// so tests can substitute a fake.
+var runOSAScript = func(script string) ([]byte, error) {
+	return exec.Command("osascript", "-e", script).Output()
+}
+
+// SetPasteboardTypes sets the ordered list of pasteboard types the macOS
+// backend reads; the first type present on the pasteboard wins. An empty
+// list restores DefaultPasteboardTypes. On Windows only the rich types are
+// taken from it: public.html and public.rtf listed before plain text are
+// read, in that order, when present (see richTypes). It has no effect
+// elsewhere.
+func (c *Clipboard) SetPasteboardTypes(types []string) error {
+	for _, t := range types {
+		if _, ok := pasteboardContentTypes[t]; !ok {
+			return fmt.Errorf("unsupported pasteboard type %q", t)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pbTypes = append([]string(nil), types...)
+	return nil
+}
+
+func (c *Clipboard) pasteboardTypes() []string {
+	types := c.pbTypes
+	if len(types) == 0 {
+		types = DefaultPasteboardTypes
+	}
+	if c.noImages {
+		types = slices.DeleteFunc(slices.Clone(types), func(t string) bool {
+			return pasteboardContentTypes[t] == TypeImage
+		})
+	}
+	return types
+}
+
+// richTypes returns the rich-text types (public.html, public.rtf) that come
+// before plain text in the configured priority, in priority order. The
+// Windows backend reads only these from the list.
+func (c *Clipboard) richTypes() []string {
+	var rich []string
+	for _, t := range c.pasteboardTypes() {
+		switch t {
+		case UTIPlainText:
+			return rich
+		case UTIHTML, UTIRTF:
+			rich = append(rich, t)
+		}
+	}
+	return rich
+}
+
+// readPasteboard reads the first available type in types with a single
+// osascript invocation.
+func readPasteboard(types []string) (*Content, error) {
+	output, err := runOSAScript(pasteboardReadScript(types))
+	if err != nil {
+		return nil, err
+	}
+	uti, data, concealed, err := parsePasteboardOutput(output)
+	if err != nil {
+		return nil, err
+	}
+	ct, ok := pasteboardContentTypes[uti]
+	if !ok {
+		return nil, fmt.Errorf("unexpected pasteboard type %q", uti)
+	}
+	if ct == TypeImage && len(data) > maxImageBytes {
+		return nil, fmt.Errorf("image too large (%d bytes, max %d)", len(data), maxImageBytes)
+	}
+	return &Content{Type: ct, Data: data, Hash: HashData(data), Format: uti, Concealed: concealed}, nil
+}
+
+// pasteboardReadScript builds an AppleScript that walks types in order and
+// returns "<uti>:<base64 data>" for the first one present, followed by
+// ":concealed" if the pasteboard carries the concealed marker. Plain text is read
+// through NSString so it is always UTF-8; TIFF is converted to PNG and tagged
+// as such. Types are validated by SetPasteboardTypes and contain no quotes.
+func pasteboardReadScript(types []string) string {
+	quoted := make([]string, len(types))
+	for i, t := range types {
+		quoted[i] = `"` + t + `"`
+	}
+	return fmt.Sprintf(`use framework "AppKit"
+use framework "Foundation"
+use scripting additions
+
+set theClipboard to current application's NSPasteboard's generalPasteboard()
+set concealed to ""
+if ((theClipboard's types()) as list) contains "%s" then set concealed to ":concealed"
+repeat with theType in {%s}
+    set theType to theType as text
+    if theType is "%s" then
+        set theString to (theClipboard's stringForType:theType)
+        if theString is not missing value then
+            set nsData to (theString's dataUsingEncoding:(current application's NSUTF8StringEncoding))
+            return theType & ":" & ((nsData's base64EncodedStringWithOptions:0) as text) & concealed
+        end if
+    else
+        set theData to (theClipboard's dataForType:theType)
+        if theData is not missing value then
+            if theType is "%s" then
+                set imgRep to (current application's NSBitmapImageRep's imageRepWithData:theData)
+                if imgRep is not missing value then
+                    set pngData to (imgRep's representationUsingType:(current application's NSBitmapImageFileTypePNG) |properties|:(missing value))
+                    return "%s:" & ((pngData's base64EncodedStringWithOptions:0) as text) & concealed
+                end if
+            else
+                return theType & ":" & ((theData's base64EncodedStringWithOptions:0) as text) & concealed
+            end if
+        end if
+    end if
+end repeat
+error "No supported pasteboard type"`, UTIConcealed, strings.Join(quoted, ", "), UTIPlainText, UTITIFF, UTIPNG)
+}
+
+// parsePasteboardOutput splits the read script's "<uti>:<base64>[:concealed]"
+// output.
+func parsePasteboardOutput(output []byte) (uti string, data []byte, concealed bool, err error) {
+	output = bytes.TrimSpace(output)
+	i := bytes.IndexByte(output, ':')
+	if i <= 0 {
+		return "", nil, false, errors.New("malformed pasteboard output")
+	}
+	uti, encoded := string(output[:i]), output[i+1:]
+	if rest, ok := bytes.CutSuffix(encoded, []byte(":concealed")); ok {
+		encoded, concealed = rest, true
+	}
+	data, err = base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return "", nil, false, fmt.Errorf("malformed pasteboard data: %w", err)
+	}
+	return uti, data, concealed, nil
+}
@@ -0,0 +1,172 @@
+package clipboard
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func fakeOSAScript(t *testing.T, output string, err error) *string {
+	t.Helper()
+	var got string
+	orig := runOSAScript
+	runOSAScript = func(script string) ([]byte, error) {
+		got = script
+		return []byte(output), err
+	}
+	t.Cleanup(func() { runOSAScript = orig })
+	return &got
+}
+
+func TestPasteboardReadScript_Order(t *testing.T) {
+	script := pasteboardReadScript([]string{UTIPlainText, UTIPNG})
+	if !strings.Contains(script, `repeat with theType in {"public.utf8-plain-text", "public.png"}`) {
+		t.Errorf("script does not walk types in configured order:\n%s", script)
+	}
+}
+
+func TestReadPasteboard_Text(t *testing.T) {
+	script := fakeOSAScript(t, "public.utf8-plain-text:aGVsbG8=\n", nil)
+
+	content, err := readPasteboard(DefaultPasteboardTypes)
+	if err != nil {
+		t.Fatalf("readPasteboard: %v", err)
+	}
+	if content.Type != TypeText || string(content.Data) != "hello" {
+		t.Errorf("got %v %q, want text \"hello\"", content.Type, content.Data)
+	}
+	if content.Format != UTIPlainText {
+		t.Errorf("Format = %q, want %q", content.Format, UTIPlainText)
+	}
+	if content.Hash != HashData([]byte("hello")) {
+		t.Error("Hash does not match content")
+	}
+	if !strings.Contains(*script, `"public.png", "public.tiff", "public.utf8-plain-text"`) {
+		t.Error("default priority not used")
+	}
+}
+
+func TestReadPasteboard_Image(t *testing.T) {
+	fakeOSAScript(t, "public.png:iVBORw==", nil)
+
+	content, err := readPasteboard(DefaultPasteboardTypes)
+	if err != nil {
+		t.Fatalf("readPasteboard: %v", err)
+	}
+	if content.Type != TypeImage || !bytes.Equal(content.Data, []byte{0x89, 'P', 'N', 'G'}) {
+		t.Errorf("got %v %x, want PNG image", content.Type, content.Data)
+	}
+}
+
+func TestReadPasteboard_HTML(t *testing.T) {
+	fakeOSAScript(t, "public.html:PGI+aGk8L2I+", nil) // <b>hi</b>
+
+	content, err := readPasteboard([]string{UTIHTML, UTIPlainText})
+	if err != nil {
+		t.Fatalf("readPasteboard: %v", err)
+	}
+	if content.Type != TypeHTML || string(content.Data) != "<b>hi</b>" {
+		t.Errorf("got %v %q, want html \"<b>hi</b>\"", content.Type, content.Data)
+	}
+	if content.Hash == HashData([]byte("hi")) {
+		t.Error("HTML content hashed like its plain-text rendering")
+	}
+}
+
+func TestReadPasteboard_Concealed(t *testing.T) {
+	fakeOSAScript(t, "public.utf8-plain-text:aHVudGVyMg==:concealed\n", nil)
+
+	content, err := readPasteboard(DefaultPasteboardTypes)
+	if err != nil {
+		t.Fatalf("readPasteboard: %v", err)
+	}
+	if !content.Concealed || string(content.Data) != "hunter2" {
+		t.Errorf("got concealed=%v %q, want concealed \"hunter2\"", content.Concealed, content.Data)
+	}
+}
+
+func TestReadPasteboard_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		output string
+		err    error
+	}{
+		{"script error", "", errors.New("no supported type")},
+		{"no tag", "aGVsbG8=", nil},
+		{"bad base64", "public.png:!!!", nil},
+		{"unknown tag", "com.example.custom:aGVsbG8=", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeOSAScript(t, tc.output, tc.err)
+			if _, err := readPasteboard(DefaultPasteboardTypes); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestSetPasteboardTypes(t *testing.T) {
+	c := New(nil)
+	if err := c.SetPasteboardTypes([]string{"com.adobe.pdf"}); err == nil {
+		t.Error("expected error for unsupported type")
+	}
+	if err := c.SetPasteboardTypes([]string{UTIPlainText, UTIPNG}); err != nil {
+		t.Fatalf("SetPasteboardTypes: %v", err)
+	}
+	if got := c.pasteboardTypes(); len(got) != 2 || got[0] != UTIPlainText {
+		t.Errorf("pasteboardTypes = %v", got)
+	}
+	if err := c.SetPasteboardTypes(nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.pasteboardTypes(); len(got) != len(DefaultPasteboardTypes) {
+		t.Errorf("empty list should restore defaults, got %v", got)
+	}
+}
+
+func TestSetNoImages_DropsImagePasteboardTypes(t *testing.T) {
+	c := New(nil)
+	c.SetNoImages(true)
+	if got := c.pasteboardTypes(); !slices.Equal(got, []string{UTIPlainText}) {
+		t.Errorf("default types without images = %v, want [%s]", got, UTIPlainText)
+	}
+	if len(DefaultPasteboardTypes) != 3 {
+		t.Errorf("SetNoImages modified DefaultPasteboardTypes: %v", DefaultPasteboardTypes)
+	}
+
+	if err := c.SetPasteboardTypes([]string{UTIHTML, UTIPNG, UTIPlainText}); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.pasteboardTypes(); !slices.Equal(got, []string{UTIHTML, UTIPlainText}) {
+		t.Errorf("configured types without images = %v", got)
+	}
+
+	c.SetNoImages(false)
+	if got := c.pasteboardTypes(); len(got) != 3 {
+		t.Errorf("SetNoImages(false) should restore images, got %v", got)
+	}
+}
+
+func TestRichTypes(t *testing.T) {
+	tests := []struct {
+		types, want []string
+	}{
+		{nil, nil},
+		{[]string{UTIRTF, UTIPlainText}, []string{UTIRTF}},
+		{[]string{UTIPNG, UTIRTF, UTIHTML, UTIPlainText}, []string{UTIRTF, UTIHTML}},
+		{[]string{UTIHTML, UTIPlainText, UTIRTF}, []string{UTIHTML}},
+		{[]string{UTIPlainText, UTIHTML, UTIRTF}, nil},
+		{[]string{UTIRTF}, []string{UTIRTF}},
+	}
+	for _, tc := range tests {
+		c := New(nil)
+		if err := c.SetPasteboardTypes(tc.types); err != nil {
+			t.Fatal(err)
+		}
+		if got := c.richTypes(); !slices.Equal(got, tc.want) {
+			t.Errorf("richTypes(%v) = %v, want %v", tc.types, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package clipboard
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// rtfSkipDestinations are RTF groups whose text is not document content.
+var rtfSkipDestinations = map[string]bool{
+	"fonttbl": true, "colortbl": true, "stylesheet": true, "info": true,
+	"pict": true, "header": true, "footer": true, "listtable": true,
+	"listoverridetable": true, "expandedcolortbl": true,
+}
+
+// rtfToText returns the plain text of an RTF document, for clipboards that
+// need a text rendering alongside the RTF. It understands paragraph and tab
+// control words, hex (\'hh, read as Windows-1252/Latin-1) and \u escapes,
+// and skips font tables, pictures and other non-text groups. It is not a
+// full RTF reader.
+func rtfToText(doc []byte) []byte {
+	var b strings.Builder
+	var u16 []uint16 // pending UTF-16 code units from \u, for surrogate pairs
+	flush := func() {
+		if len(u16) > 0 {
+			b.WriteString(string(utf16.Decode(u16)))
+			u16 = u16[:0]
+		}
+	}
+
+	type group struct{ skip bool }
+	stack := []group{{}}
+	skipping := func() bool { return stack[len(stack)-1].skip }
+	ucN, ucSkip := 1, 0 // \ucN fallback length; fallback characters left to drop
+
+	s := string(doc)
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '{':
+			stack = append(stack, stack[len(stack)-1])
+			i++
+		case c == '}':
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+			i++
+		case c == '\r' || c == '\n':
+			i++
+		case c == '\\' && i+1 < len(s):
+			i++
+			c = s[i]
+			switch {
+			case c == '\'' && i+2 < len(s):
+				if v, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil && !skipping() {
+					if ucSkip > 0 {
+						ucSkip--
+					} else {
+						flush()
+						b.WriteRune(rune(v))
+					}
+				}
+				i += 3
+			case c == '*':
+				stack[len(stack)-1].skip = true
+				i++
+			case isASCIILetter(c):
+				start := i
+				for i < len(s) && isASCIILetter(s[i]) {
+					i++
+				}
+				word := s[start:i]
+				numStart := i
+				if i < len(s) && s[i] == '-' {
+					i++
+				}
+				for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+					i++
+				}
+				param, hasParam := 0, i > numStart
+				if hasParam {
+					param, _ = strconv.Atoi(s[numStart:i])
+				}
+				if i < len(s) && s[i] == ' ' {
+					i++ // the delimiting space belongs to the control word
+				}
+				if rtfSkipDestinations[word] {
+					stack[len(stack)-1].skip = true
+				}
+				if skipping() {
+					continue
+				}
+				switch word {
+				case "par", "line", "row":
+					flush()
+					b.WriteByte('\n')
+				case "tab", "cell":
+					flush()
+					b.WriteByte('\t')
+				case "uc":
+					ucN = param
+				case "u":
+					if hasParam {
+						u16 = append(u16, uint16(int16(param)))
+						ucSkip = ucN
+					}
+				}
+			default: // control symbol, e.g. \\ \{ \} \~
+				if !skipping() {
+					flush()
+					switch c {
+					case '\\', '{', '}':
+						b.WriteByte(c)
+					case '~':
+						b.WriteByte(' ')
+					}
+				}
+				i++
+			}
+		default:
+			if !skipping() {
+				if ucSkip > 0 {
+					ucSkip--
+				} else {
+					flush()
+					b.WriteByte(c)
+				}
+			}
+			i++
+		}
+	}
+	flush()
+	return []byte(strings.TrimSpace(b.String()))
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
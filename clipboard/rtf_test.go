@@ -0,0 +1,26 @@
+package clipboard
+
+import "testing"
+
+func TestRTFToText(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"plain", `{\rtf1\ansi hello}`, "hello"},
+		{"formatting", `{\rtf1\ansi {\b bold} and \i italic\i0  text}`, "bold and italic text"},
+		{"paragraphs", `{\rtf1 one\par two\par}`, "one\ntwo"},
+		{"font table", `{\rtf1{\fonttbl{\f0 Helvetica;}}{\colortbl;\red0\green0\blue0;}\f0 body}`, "body"},
+		{"ignorable destination", `{\rtf1{\*\generator Cocoa;}text}`, "text"},
+		{"escaped symbols", `{\rtf1 a\{b\}c\\d}`, `a{b}c\d`},
+		{"hex escape", `{\rtf1 caf\'e9}`, "café"},
+		{"unicode with fallback", `{\rtf1\uc1 \u8364?5}`, "€5"},
+		{"surrogate pair", `{\rtf1\uc1 \u-10179?\u-8704?}`, "😀"},
+		{"longer fallback", `{\rtf1\uc2 \u8364??5}`, "€5"},
+		{"tab", `{\rtf1 a\tab b}`, "a\tb"},
+	}
+	for _, tc := range tests {
+		if got := string(rtfToText([]byte(tc.in))); got != tc.want {
+			t.Errorf("%s: rtfToText(%q) = %q, want %q", tc.name, tc.in, got, tc.want)
+		}
+	}
+}
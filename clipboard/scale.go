@@ -0,0 +1,86 @@
+package clipboard
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+)
+
+// DownscalePNG shrinks a PNG so that neither side exceeds maxDim pixels,
+// preserving the aspect ratio, and returns it re-encoded as PNG. Images that
+// already fit are returned unchanged with scaled=false.
+func DownscalePNG(data []byte, maxDim int) (out []byte, scaled bool, err error) {
+	if maxDim <= 0 {
+		return data, false, nil
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid PNG: %w", err)
+	}
+	if cfg.Width <= maxDim && cfg.Height <= maxDim {
+		return data, false, nil
+	}
+	if uint64(cfg.Width)*uint64(cfg.Height) > maxPNGPixels {
+		return nil, false, fmt.Errorf("PNG too large to scale (%dx%d)", cfg.Width, cfg.Height)
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid PNG: %w", err)
+	}
+	w, h := scaledSize(cfg.Width, cfg.Height, maxDim)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, boxResize(img, w, h)); err != nil {
+		return nil, false, err
+	}
+	return buf.Bytes(), true, nil
+}
+
+// scaledSize fits w×h within maxDim×maxDim, keeping the aspect ratio and
+// never rounding a side down to zero.
+func scaledSize(w, h, maxDim int) (int, int) {
+	if w >= h {
+		return maxDim, max(1, (h*maxDim+w/2)/w)
+	}
+	return max(1, (w*maxDim+h/2)/h), maxDim
+}
+
+// boxResize downscales src to w×h by averaging each destination pixel's
+// source area. Averaging premultiplied RGBA keeps transparent edges from
+// bleeding dark fringes. Only suitable for shrinking.
+func boxResize(src image.Image, w, h int) *image.RGBA {
+	b := src.Bounds()
+	rgba, ok := src.(*image.RGBA)
+	if !ok || b.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	}
+	sw, sh := b.Dx(), b.Dy()
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, (y+1)*sh/h
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, (x+1)*sw/w
+			var sum [4]uint64
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride+x0*4 : sy*rgba.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += uint64(row[i])
+					sum[1] += uint64(row[i+1])
+					sum[2] += uint64(row[i+2])
+					sum[3] += uint64(row[i+3])
+				}
+			}
+			n := uint64((y1 - y0) * (x1 - x0))
+			o := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[o+c] = uint8((sum[c] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,87 @@
+package clipboard
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func encodeTestPNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDownscalePNG_PreservesAspectRatio(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 4000, 2250))
+	for i := range src.Pix {
+		src.Pix[i] = 0xff
+	}
+
+	out, scaled, err := DownscalePNG(encodeTestPNG(t, src), 1920)
+	if err != nil {
+		t.Fatalf("DownscalePNG: %v", err)
+	}
+	if !scaled {
+		t.Fatal("4000px image was not scaled")
+	}
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	if got := img.Bounds().Size(); got != (image.Point{1920, 1080}) {
+		t.Errorf("scaled to %v, want 1920x1080", got)
+	}
+	if c := color.RGBAModel.Convert(img.At(960, 540)).(color.RGBA); c != (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("pixel = %v, want opaque white", c)
+	}
+}
+
+func TestDownscalePNG_PortraitBound(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 300, 4000))
+	out, _, err := DownscalePNG(encodeTestPNG(t, src), 1000)
+	if err != nil {
+		t.Fatalf("DownscalePNG: %v", err)
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	if cfg.Width != 75 || cfg.Height != 1000 {
+		t.Errorf("scaled to %dx%d, want 75x1000", cfg.Width, cfg.Height)
+	}
+}
+
+func TestDownscalePNG_SmallImageUnchanged(t *testing.T) {
+	data := encodeTestPNG(t, image.NewRGBA(image.Rect(0, 0, 100, 50)))
+	out, scaled, err := DownscalePNG(data, 1920)
+	if err != nil {
+		t.Fatalf("DownscalePNG: %v", err)
+	}
+	if scaled || !bytes.Equal(out, data) {
+		t.Error("image within bound was modified")
+	}
+}
+
+func TestDownscalePNG_AveragesPixels(t *testing.T) {
+	// Alternating black and white columns average to mid grey.
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			v := uint8(0)
+			if x%2 == 1 {
+				v = 0xff
+			}
+			src.Set(x, y, color.RGBA{v, v, v, 0xff})
+		}
+	}
+	got := boxResize(src, 2, 1)
+	if c := got.RGBAAt(0, 0); c.R != 0x80 || c.A != 0xff {
+		t.Errorf("averaged pixel = %v, want {128 128 128 255}", c)
+	}
+}
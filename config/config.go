@@ -5,14 +5,22 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 )
 
 // Clipboard represents a single named sync clipboard
 type Clipboard struct {
-	Name    string `json:"name"`
-	Enabled bool   `json:"enabled"`
+	Name      string `json:"name"`
+	Enabled   bool   `json:"enabled"`
+	Direction string `json:"direction,omitempty"` // "", "send", "recv"; empty = both ways
 }
 
+// Sync directions for Clipboard.Direction.
+const (
+	DirectionSend = "send" // publish local copies; ignore incoming
+	DirectionRecv = "recv" // apply incoming; never publish
+)
+
 // RelayConfig holds Ably relay settings.
 // The API key is stored in the system keychain, not here.
 type RelayConfig struct {
@@ -37,10 +45,37 @@ type Config struct {
 	ClearAfterSeconds int         `json:"clear_after_seconds"` // 0 = disabled
 	JiggleMode        string      `json:"jiggle_mode"`         // "", "minimal", "natural"
 	IsHub             bool        `json:"is_hub"`
-	HubTargets        []string    `json:"hub_targets"` // empty = broadcast to all; only used when IsHub=true
+	HubTargets        []string    `json:"hub_targets"`      // empty = broadcast to all; only used when IsHub=true
+	Hash              string      `json:"hash"`             // "", "sha256", "maphash"; local change detection only
+	Hotkey            string      `json:"hotkey"`           // e.g. "ctrl+alt+v"; non-empty = publish only on hotkey press
+	SyncOnConnect     bool        `json:"sync_on_connect"`  // replay the latest clipboard on connect
+	PasteboardTypes   []string    `json:"pasteboard_types"` // macOS read priority (Windows: html before text); empty = png, tiff, text
+	StatusAddr        string      `json:"status_addr"`      // e.g. "127.0.0.1:7777"; empty = no status endpoint
+	Compress          bool        `json:"compress"`         // gzip payloads when smaller; all peers must support it
+	AllowFetch        bool        `json:"allow_fetch"`      // send and answer -paste fetch requests; all peers must support it
+	SyncTypes         []string    `json:"sync_types"`       // "text", "image", "html", "rtf"; empty = all
+	NoImage           bool        `json:"no_image"`         // never read images from the clipboard, e.g. on headless machines
+	MaxImageDim       int         `json:"max_image_dim"`    // downscale outgoing images to this many pixels per side; 0 = off
+	JPEGQuality       int         `json:"jpeg_quality"`     // send photos too big for one message as JPEG at this quality (1-100); 0 = off
+	PersistHash       bool        `json:"persist_hash"`     // remember the last clipboard hash across restarts
+	LogFormat         string      `json:"log_format"`       // "", "text", "json"
+	InboundRate       float64     `json:"inbound_rate"`     // clipboard writes/sec per clipboard; 0 = default, negative = unlimited
+	ExcludePatterns   []string    `json:"exclude_patterns"` // regexps; matching text is never published
+	SyncConcealed     bool        `json:"sync_concealed"`   // also publish content marked as a secret by its source
+	CoalesceMs        int         `json:"coalesce_ms"`      // publish only the last of a burst of copies, after this quiet period; 0 = off
+	DryRun            bool        `json:"-"`                // command line only: log instead of syncing
 	Relay             RelayConfig `json:"relay"`
 }
 
+// ExcludeRegexps compiles ExcludePatterns, which Validate has checked.
+func (cfg *Config) ExcludeRegexps() []*regexp.Regexp {
+	var out []*regexp.Regexp
+	for _, p := range cfg.ExcludePatterns {
+		out = append(out, regexp.MustCompile(p))
+	}
+	return out
+}
+
 // Validate checks the configuration for semantic errors that would cause a
 // runtime panic or silent misbehaviour.  It is called automatically by
 // LoadFrom after successful JSON unmarshalling.
@@ -48,10 +83,42 @@ func (cfg *Config) Validate() error {
 	if cfg.PollMs <= 0 {
 		return fmt.Errorf("poll_ms must be positive (got %d); check your config file", cfg.PollMs)
 	}
+	if cfg.CoalesceMs < 0 {
+		return fmt.Errorf("coalesce_ms must not be negative (got %d)", cfg.CoalesceMs)
+	}
+	if cfg.JPEGQuality < 0 || cfg.JPEGQuality > 100 {
+		return fmt.Errorf("jpeg_quality must be between 0 and 100 (got %d)", cfg.JPEGQuality)
+	}
+	if cfg.MaxImageDim < 0 {
+		return fmt.Errorf("max_image_dim must not be negative (got %d)", cfg.MaxImageDim)
+	}
+	switch cfg.Hash {
+	case "", "sha256", "maphash":
+	default:
+		return fmt.Errorf("hash must be \"sha256\" or \"maphash\" (got %q)", cfg.Hash)
+	}
+	switch cfg.LogFormat {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("log_format must be \"text\" or \"json\" (got %q)", cfg.LogFormat)
+	}
+	for _, p := range cfg.ExcludePatterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("exclude_patterns: %w", err)
+		}
+	}
+	if cfg.PersistHash && cfg.Hash == "maphash" {
+		return fmt.Errorf("persist_hash requires the sha256 hash: maphash digests change every run")
+	}
 	for i, cb := range cfg.Relay.Clipboards {
 		if cb.Name == "" {
 			return fmt.Errorf("relay.clipboards[%d] has an empty name", i)
 		}
+		switch cb.Direction {
+		case "", DirectionSend, DirectionRecv:
+		default:
+			return fmt.Errorf("relay.clipboards[%d] direction must be \"send\" or \"recv\" (got %q)", i, cb.Direction)
+		}
 	}
 	return nil
 }
@@ -132,5 +199,30 @@ func SaveTo(path string, cfg *Config) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0600)
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory and
+// renames it over path, so a crash or kill mid-write leaves either the old
+// file or the new one — never a truncated config. The file is created 0600.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // no-op once renamed
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
 }
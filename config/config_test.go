@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -158,6 +159,47 @@ func TestSaveToFilePermissions(t *testing.T) {
 	}
 }
 
+func TestSaveToReplacesAtomically(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+
+	if err := SaveTo(path, DefaultConfig()); err != nil {
+		t.Fatalf("SaveTo: %v", err)
+	}
+	cfg := DefaultConfig()
+	cfg.PollMs = 750
+	if err := SaveTo(path, cfg); err != nil {
+		t.Fatalf("SaveTo (overwrite): %v", err)
+	}
+
+	loaded, err := LoadFrom(path)
+	if err != nil {
+		t.Fatalf("LoadFrom: %v", err)
+	}
+	if loaded.PollMs != 750 {
+		t.Errorf("expected PollMs=750 after overwrite, got %d", loaded.PollMs)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		names := make([]string, len(entries))
+		for i, e := range entries {
+			names[i] = e.Name()
+		}
+		t.Errorf("expected only config.json in dir, got %v", names)
+	}
+}
+
+func TestSaveToMissingDirLeavesNothing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "config.json")
+	if err := SaveTo(path, DefaultConfig()); err == nil {
+		t.Error("expected error saving into a missing directory, got nil")
+	}
+}
+
 func TestLoadFromPartialJSONUsesDefaults(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "config.json")
@@ -204,6 +246,37 @@ func TestValidate_NegativePollMs_ReturnsError(t *testing.T) {
 	}
 }
 
+func TestValidate_NegativeCoalesceMs_ReturnsError(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.CoalesceMs = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected Validate to return error for coalesce_ms=-1, got nil")
+	}
+}
+
+func TestValidate_JPEGQualityRange(t *testing.T) {
+	for _, q := range []int{-1, 101} {
+		cfg := DefaultConfig()
+		cfg.JPEGQuality = q
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("expected Validate to return error for jpeg_quality=%d, got nil", q)
+		}
+	}
+	cfg := DefaultConfig()
+	cfg.JPEGQuality = 85
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("jpeg_quality=85: %v", err)
+	}
+}
+
+func TestValidate_NegativeMaxImageDim_ReturnsError(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxImageDim = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected Validate to return error for max_image_dim=-1, got nil")
+	}
+}
+
 func TestValidate_EmptyClipboardName_ReturnsError(t *testing.T) {
 	cfg := DefaultConfig()
 	cfg.Relay.Clipboards = []Clipboard{{Name: "", Enabled: true}}
@@ -212,6 +285,78 @@ func TestValidate_EmptyClipboardName_ReturnsError(t *testing.T) {
 	}
 }
 
+func TestValidate_Hash(t *testing.T) {
+	for _, h := range []string{"", "sha256", "maphash"} {
+		cfg := DefaultConfig()
+		cfg.Hash = h
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("hash=%q: expected valid, got %v", h, err)
+		}
+	}
+	cfg := DefaultConfig()
+	cfg.Hash = "md5"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected Validate to return error for hash=md5, got nil")
+	}
+}
+
+func TestValidate_LogFormat(t *testing.T) {
+	for _, f := range []string{"", "text", "json"} {
+		cfg := DefaultConfig()
+		cfg.LogFormat = f
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("log_format %q: unexpected error %v", f, err)
+		}
+	}
+	cfg := DefaultConfig()
+	cfg.LogFormat = "xml"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected Validate to reject log_format \"xml\"")
+	}
+}
+
+func TestValidate_ExcludePatterns(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ExcludePatterns = []string{`^ghp_[A-Za-z0-9]{36}$`}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("valid pattern rejected: %v", err)
+	}
+	if got := cfg.ExcludeRegexps(); len(got) != 1 || !got[0].MatchString("ghp_"+strings.Repeat("a", 36)) {
+		t.Errorf("ExcludeRegexps = %v", got)
+	}
+	cfg.ExcludePatterns = []string{"(unclosed"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected Validate to reject an invalid regexp")
+	}
+}
+
+func TestValidate_PersistHashNeedsSHA256(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.PersistHash = true
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("persist_hash with default hash: %v", err)
+	}
+	cfg.Hash = "maphash"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected Validate to reject persist_hash with maphash")
+	}
+}
+
+func TestValidate_Direction(t *testing.T) {
+	for _, d := range []string{"", DirectionSend, DirectionRecv} {
+		cfg := DefaultConfig()
+		cfg.Relay.Clipboards = []Clipboard{{Name: "work", Enabled: true, Direction: d}}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("direction=%q: expected valid, got %v", d, err)
+		}
+	}
+	cfg := DefaultConfig()
+	cfg.Relay.Clipboards = []Clipboard{{Name: "work", Enabled: true, Direction: "both"}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected Validate to return error for direction=both, got nil")
+	}
+}
+
 func TestLoadFromZeroPollMs_ReturnsDefaultAndError(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "config.json")
@@ -7,6 +7,7 @@ require (
 	github.com/ably/ably-go v1.3.0
 	github.com/zalando/go-keyring v0.2.8
 	golang.org/x/crypto v0.0.0-20210322153248-0c34fe9e7dc2
+	golang.org/x/sys v0.27.0
 )
 
 require (
@@ -15,6 +16,5 @@ require (
 	github.com/godbus/dbus/v5 v5.2.2 // indirect
 	github.com/klauspost/compress v1.10.3 // indirect
 	github.com/ugorji/go/codec v1.1.9 // indirect
-	golang.org/x/sys v0.27.0 // indirect
 	nhooyr.io/websocket v1.8.7 // indirect
 )
@@ -0,0 +1,91 @@
+// Package hotkey listens for a global keyboard shortcut so the clipboard can
+// be pushed explicitly instead of on every change.
+package hotkey
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Modifier is a bitset of modifier keys.
+type Modifier uint8
+
+const (
+	ModCtrl Modifier = 1 << iota
+	ModAlt
+	ModShift
+	ModCmd // Command on macOS, the Windows key on Windows
+)
+
+// Hotkey is a modifier chord plus a single letter or digit key.
+type Hotkey struct {
+	Mods Modifier
+	Key  rune // 'A'-'Z' or '0'-'9'
+}
+
+// ErrUnsupported is returned by Listen on platforms without a hotkey backend.
+var ErrUnsupported = errors.New("global hotkeys are not supported on this platform")
+
+// Source delivers one value on Events each time the hotkey is pressed.
+type Source interface {
+	Events() <-chan struct{}
+	Close() error
+}
+
+// Parse parses a chord such as "ctrl+alt+v" or "cmd+shift+c". At least one
+// modifier is required so a bare key is never grabbed system-wide.
+func Parse(s string) (Hotkey, error) {
+	var hk Hotkey
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(s)), "+")
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		if i == len(parts)-1 {
+			if len(p) != 1 || !(p[0] >= 'a' && p[0] <= 'z' || p[0] >= '0' && p[0] <= '9') {
+				return Hotkey{}, fmt.Errorf("invalid hotkey %q: key must be a single letter or digit", s)
+			}
+			hk.Key = rune(strings.ToUpper(p)[0])
+			break
+		}
+		switch p {
+		case "ctrl", "control":
+			hk.Mods |= ModCtrl
+		case "alt", "opt", "option":
+			hk.Mods |= ModAlt
+		case "shift":
+			hk.Mods |= ModShift
+		case "cmd", "command", "win", "super", "meta":
+			hk.Mods |= ModCmd
+		default:
+			return Hotkey{}, fmt.Errorf("invalid hotkey %q: unknown modifier %q", s, p)
+		}
+	}
+	if hk.Mods == 0 {
+		return Hotkey{}, fmt.Errorf("invalid hotkey %q: at least one modifier is required", s)
+	}
+	return hk, nil
+}
+
+// String formats the hotkey in the form accepted by Parse.
+func (hk Hotkey) String() string {
+	var parts []string
+	if hk.Mods&ModCtrl != 0 {
+		parts = append(parts, "ctrl")
+	}
+	if hk.Mods&ModAlt != 0 {
+		parts = append(parts, "alt")
+	}
+	if hk.Mods&ModShift != 0 {
+		parts = append(parts, "shift")
+	}
+	if hk.Mods&ModCmd != 0 {
+		parts = append(parts, "cmd")
+	}
+	return strings.Join(append(parts, strings.ToLower(string(hk.Key))), "+")
+}
+
+// Listen registers hk system-wide and returns a Source for its presses.
+// It returns ErrUnsupported where no backend exists.
+func Listen(hk Hotkey) (Source, error) {
+	return listen(hk)
+}
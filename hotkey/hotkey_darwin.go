@@ -0,0 +1,91 @@
+//go:build darwin && cgo
+
+package hotkey
+
+/*
+#cgo LDFLAGS: -framework CoreGraphics
+#include <CoreGraphics/CoreGraphics.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"time"
+)
+
+// pollInterval is how often the key state is sampled. Polling the combined
+// session state avoids an event tap, which would need a CFRunLoop thread and
+// an Accessibility grant.
+const pollInterval = 50 * time.Millisecond
+
+// ansiKeyCodes maps letters and digits to macOS virtual key codes
+// (kVK_ANSI_*), which follow the physical ANSI layout, not the alphabet.
+var ansiKeyCodes = map[rune]C.CGKeyCode{
+	'A': 0x00, 'S': 0x01, 'D': 0x02, 'F': 0x03, 'H': 0x04, 'G': 0x05,
+	'Z': 0x06, 'X': 0x07, 'C': 0x08, 'V': 0x09, 'B': 0x0B, 'Q': 0x0C,
+	'W': 0x0D, 'E': 0x0E, 'R': 0x0F, 'Y': 0x10, 'T': 0x11, '1': 0x12,
+	'2': 0x13, '3': 0x14, '4': 0x15, '6': 0x16, '5': 0x17, '9': 0x19,
+	'7': 0x1A, '8': 0x1C, '0': 0x1D, 'O': 0x1F, 'U': 0x20, 'I': 0x22,
+	'P': 0x23, 'L': 0x25, 'J': 0x26, 'K': 0x28, 'N': 0x2D, 'M': 0x2E,
+}
+
+type darwinSource struct {
+	events chan struct{}
+	done   chan struct{}
+}
+
+func (s *darwinSource) Events() <-chan struct{} { return s.events }
+
+func (s *darwinSource) Close() error {
+	close(s.done)
+	return nil
+}
+
+func listen(hk Hotkey) (Source, error) {
+	code, ok := ansiKeyCodes[hk.Key]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hotkey key %q", hk.Key)
+	}
+	var want C.CGEventFlags
+	if hk.Mods&ModCtrl != 0 {
+		want |= C.kCGEventFlagMaskControl
+	}
+	if hk.Mods&ModAlt != 0 {
+		want |= C.kCGEventFlagMaskAlternate
+	}
+	if hk.Mods&ModShift != 0 {
+		want |= C.kCGEventFlagMaskShift
+	}
+	if hk.Mods&ModCmd != 0 {
+		want |= C.kCGEventFlagMaskCommand
+	}
+	const mask = C.kCGEventFlagMaskControl | C.kCGEventFlagMaskAlternate | C.kCGEventFlagMaskShift | C.kCGEventFlagMaskCommand
+
+	s := &darwinSource{events: make(chan struct{}, 1), done: make(chan struct{})}
+	go func() {
+		defer close(s.events)
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		wasDown := false
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				flags := C.CGEventSourceFlagsState(C.kCGEventSourceStateCombinedSessionState)
+				down := flags&mask == want &&
+					bool(C.CGEventSourceKeyState(C.kCGEventSourceStateCombinedSessionState, code))
+				// Fire on the press edge only, so holding the chord sends once.
+				if down && !wasDown {
+					select {
+					case s.events <- struct{}{}:
+					default:
+					}
+				}
+				wasDown = down
+			}
+		}
+	}()
+	return s, nil
+}
@@ -0,0 +1,7 @@
+//go:build !windows && !(darwin && cgo)
+
+package hotkey
+
+func listen(hk Hotkey) (Source, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,44 @@
+package hotkey
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		in   string
+		want Hotkey
+	}{
+		{"ctrl+alt+v", Hotkey{Mods: ModCtrl | ModAlt, Key: 'V'}},
+		{"Cmd+Shift+C", Hotkey{Mods: ModCmd | ModShift, Key: 'C'}},
+		{"option+command+1", Hotkey{Mods: ModAlt | ModCmd, Key: '1'}},
+		{" win + p ", Hotkey{Mods: ModCmd, Key: 'P'}},
+	}
+	for _, c := range cases {
+		got, err := Parse(c.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", c.in, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, in := range []string{"", "v", "ctrl+", "ctrl+alt", "hyper+v", "ctrl+f1", "ctrl+!"} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q): expected error", in)
+		}
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	hk := Hotkey{Mods: ModCtrl | ModShift | ModCmd, Key: 'K'}
+	got, err := Parse(hk.String())
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", hk.String(), err)
+	}
+	if got != hk {
+		t.Errorf("round trip = %+v, want %+v", got, hk)
+	}
+}
@@ -0,0 +1,117 @@
+//go:build windows
+
+package hotkey
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	user32   = syscall.NewLazyDLL("user32.dll")
+	kernel32 = syscall.NewLazyDLL("kernel32.dll")
+
+	pRegisterHotKey     = user32.NewProc("RegisterHotKey")
+	pUnregisterHotKey   = user32.NewProc("UnregisterHotKey")
+	pGetMessageW        = user32.NewProc("GetMessageW")
+	pPostThreadMessageW = user32.NewProc("PostThreadMessageW")
+	pGetCurrentThreadId = kernel32.NewProc("GetCurrentThreadId")
+)
+
+const (
+	wmQuit   = 0x0012
+	wmHotkey = 0x0312
+
+	modAlt      = 0x0001
+	modControl  = 0x0002
+	modShift    = 0x0004
+	modWin      = 0x0008
+	modNoRepeat = 0x4000
+
+	hotkeyID = 1
+)
+
+type winMSG struct {
+	HWnd    uintptr
+	Message uint32
+	WParam  uintptr
+	LParam  uintptr
+	Time    uint32
+	Pt      struct{ X, Y int32 }
+}
+
+type winSource struct {
+	events   chan struct{}
+	threadID uintptr
+}
+
+func (s *winSource) Events() <-chan struct{} { return s.events }
+
+// Close posts WM_QUIT to the listener thread, which unregisters the hotkey
+// and closes Events.
+func (s *winSource) Close() error {
+	ret, _, err := pPostThreadMessageW.Call(s.threadID, wmQuit, 0, 0)
+	if ret == 0 {
+		return fmt.Errorf("PostThreadMessage failed: %v", err)
+	}
+	return nil
+}
+
+func listen(hk Hotkey) (Source, error) {
+	var mods uintptr = modNoRepeat
+	if hk.Mods&ModCtrl != 0 {
+		mods |= modControl
+	}
+	if hk.Mods&ModAlt != 0 {
+		mods |= modAlt
+	}
+	if hk.Mods&ModShift != 0 {
+		mods |= modShift
+	}
+	if hk.Mods&ModCmd != 0 {
+		mods |= modWin
+	}
+	// Virtual-key codes for A-Z and 0-9 are their ASCII values.
+	vk := uintptr(hk.Key)
+
+	s := &winSource{events: make(chan struct{}, 1)}
+	errc := make(chan error, 1)
+
+	go func() {
+		// WM_HOTKEY is posted to the thread that registered the hotkey, so
+		// registration and the message loop must share one OS thread.
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		s.threadID, _, _ = pGetCurrentThreadId.Call()
+		ret, _, err := pRegisterHotKey.Call(0, hotkeyID, mods, vk)
+		if ret == 0 {
+			errc <- fmt.Errorf("RegisterHotKey(%s) failed: %v", hk, err)
+			return
+		}
+		defer pUnregisterHotKey.Call(0, hotkeyID)
+		errc <- nil
+
+		var msg winMSG
+		for {
+			ret, _, _ := pGetMessageW.Call(uintptr(unsafe.Pointer(&msg)), 0, 0, 0)
+			if int32(ret) <= 0 { // WM_QUIT or error
+				close(s.events)
+				return
+			}
+			if msg.Message == wmHotkey {
+				select {
+				case s.events <- struct{}{}:
+				default: // a push is already pending
+				}
+			}
+		}
+	}()
+
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,68 @@
+// Package logging adapts paperclip's log output for machine consumption.
+package logging
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Log levels inferred from message text.
+const (
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+type entry struct {
+	TS    string `json:"ts"`
+	Level string `json:"level"`
+	Msg   string `json:"msg"`
+}
+
+type jsonWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	now func() time.Time
+}
+
+// NewJSONWriter returns a writer for use with log.New(w, "", 0) that turns
+// each log line into one JSON object with ts, level and msg fields. The
+// level is inferred from the message, see Level.
+func NewJSONWriter(w io.Writer) io.Writer {
+	return &jsonWriter{w: w, now: time.Now}
+}
+
+// Write encodes one log line. *log.Logger calls Write once per message.
+func (j *jsonWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimRight(string(p), "\n")
+	line, err := json.Marshal(entry{
+		TS:    j.now().UTC().Format(time.RFC3339Nano),
+		Level: Level(msg),
+		Msg:   msg,
+	})
+	if err != nil {
+		return 0, err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.w.Write(append(line, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Level infers a message's level from the conventions used throughout
+// paperclip: "WARNING:" prefixes warnings, and "ERROR:" or "Failed"
+// prefixes errors.
+func Level(msg string) string {
+	switch {
+	case strings.HasPrefix(msg, "WARNING:"):
+		return LevelWarn
+	case strings.HasPrefix(msg, "ERROR:"), strings.HasPrefix(msg, "Failed"):
+		return LevelError
+	}
+	return LevelInfo
+}
@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewJSONWriter(&buf).(*jsonWriter)
+	w.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+	logger := log.New(w, "", 0)
+
+	logger.Printf("Received text (%d bytes) via clipboard '%s'", 5, "work")
+	logger.Printf("WARNING: empty passphrase for clipboard '%s'", "home")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", len(lines), buf.String())
+	}
+	var got entry
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatalf("line is not JSON: %v", err)
+	}
+	want := entry{TS: "2024-05-01T12:00:00Z", Level: LevelInfo, Msg: "Received text (5 bytes) via clipboard 'work'"}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &got); err != nil {
+		t.Fatalf("line is not JSON: %v", err)
+	}
+	if got.Level != LevelWarn {
+		t.Errorf("level = %q, want %q", got.Level, LevelWarn)
+	}
+}
+
+func TestLevel(t *testing.T) {
+	for msg, want := range map[string]string{
+		"Starting paperclip":                      LevelInfo,
+		"WARNING: hotkey unavailable":             LevelWarn,
+		"ERROR: clipboard has no key":             LevelError,
+		"Failed to write clipboard: denied":       LevelError,
+		"Encryption enabled for clipboard 'work'": LevelInfo,
+	} {
+		if got := Level(msg); got != want {
+			t.Errorf("Level(%q) = %q, want %q", msg, got, want)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package logging
+
+// Logger is the logging interface paperclip's packages accept. *log.Logger
+// satisfies it, so existing callers pass one unchanged; programs embedding
+// paperclip can route messages elsewhere, e.g. slog, with Func.
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+// Func adapts a printf-style function to Logger.
+type Func func(format string, v ...any)
+
+// Printf calls f.
+func (f Func) Printf(format string, v ...any) {
+	f(format, v...)
+}
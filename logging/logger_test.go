@@ -0,0 +1,18 @@
+package logging
+
+import (
+	"fmt"
+	"log"
+	"testing"
+)
+
+var _ Logger = (*log.Logger)(nil)
+
+func TestFunc(t *testing.T) {
+	var got string
+	var l Logger = Func(func(format string, v ...any) { got = fmt.Sprintf(format, v...) })
+	l.Printf("%d bytes via '%s'", 3, "work")
+	if got != "3 bytes via 'work'" {
+		t.Errorf("got %q", got)
+	}
+}
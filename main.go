@@ -1,16 +1,26 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/mindmorass/paperclip/clipboard"
 	"github.com/mindmorass/paperclip/config"
+	"github.com/mindmorass/paperclip/hotkey"
+	"github.com/mindmorass/paperclip/logging"
 	"github.com/mindmorass/paperclip/relay"
 	"github.com/mindmorass/paperclip/ui"
 )
@@ -19,19 +29,81 @@ var version = "0.5.0"
 
 func main() {
 	var (
-		pollMs  = flag.Int("poll", 0, "Clipboard poll interval in milliseconds")
-		showVer = flag.Bool("version", false, "Show version")
-		verbose = flag.Bool("v", false, "Verbose logging")
-		tray    = flag.Bool("tray", false, "Run with menu bar UI")
-		clipboardName = flag.String("clipboard", "", "Comma-separated clipboard names")
+		pollMs        = flag.Int("poll", 0, "Clipboard poll interval in milliseconds")
+		coalesceMs    = flag.Int("coalesce", 0, "Publish only the last of a burst of copies, once the clipboard has been quiet this many milliseconds (0 = off)")
+		showVer       = flag.Bool("version", false, "Show version")
+		verbose       = flag.Bool("v", false, "Verbose logging")
+		logFormat     = flag.String("log-format", "", "Log format: text (default) or json (one object per line)")
+		tray          = flag.Bool("tray", false, "Run with menu bar UI")
+		clipboardName = flag.String("clipboard", "", "Comma-separated clipboard names; prefix with recv: or send: for one-way sync")
+		once          = flag.Bool("once", false, "Publish the current clipboard once and exit (non-zero if no clipboard accepted it)")
+		paste         = flag.Bool("paste", false, "Ask running machines for their clipboard, print it to stdout and exit (needs -allow-fetch)")
+		allowFetch    = flag.Bool("allow-fetch", false, "Allow -paste and answer other machines' -paste requests (every machine on the clipboard must run a version that supports it)")
+		pasteOut      = flag.String("out", "", "With -paste or -recv, write the content to this file instead of stdout (required for images)")
+		send          = flag.Bool("send", false, "Publish stdin as text to the clipboards and exit, without touching the local clipboard")
+		sendImage     = flag.Bool("image", false, "With -send, stdin is a PNG image rather than text")
+		recv          = flag.Bool("recv", false, "Wait for the next item on the first clipboard, write it to stdout and exit, without touching the local clipboard")
+		testConnect   = flag.Bool("test-connect", false, "Connect to each clipboard, print its key fingerprint and exit (non-zero if any failed)")
+		systemd       = flag.Bool("systemd", false, "Write a systemd user unit for this executable and exit (Linux)")
+
+		hotkeySpec    = flag.String("hotkey", "", "Publish only when this chord is pressed, e.g. ctrl+alt+v (explicit push)")
+		syncOnConnect = flag.Bool("sync-on-connect", false, "On connect, fetch the latest clipboard copied while this machine was offline")
+		hashAlgo      = flag.String("hash", "", "Content hash for change detection: sha256 (default) or maphash (faster, local only)")
+		persistHash   = flag.Bool("persist-hash", false, "Remember the last clipboard hash across restarts so a restart does not rebroadcast the current clipboard")
+		inboundRate   = flag.Float64("inbound-rate", 0, "Max incoming clipboard writes per second per clipboard; excess is dropped (0 = default of 5, negative = unlimited)")
+		dryRun        = flag.Bool("dry-run", false, "Detect and decrypt as usual, but only log what would be published or written; never touch the clipboard or publish")
+		syncConcealed = flag.Bool("sync-concealed", false, "Also publish content that password managers mark as concealed (skipped by default)")
+		compress      = flag.Bool("compress", false, "Gzip clipboard payloads before encryption when smaller (every machine on the clipboard must run a version that supports it)")
+		syncTypes     = flag.String("sync-types", "", "Comma-separated content types to sync in both directions: text, image, html, rtf (default all)")
+		noImage       = flag.Bool("no-image", false, "Never read images from the local clipboard; copy text only (for headless machines)")
+		jpegQuality   = flag.Int("jpeg-quality", 0, "Send photos too big for one message as JPEG at this quality, 1-100 (0 = off; every machine must support it)")
+		maxImageDim   = flag.Int("max-image-dim", 0, "Downscale outgoing images so neither side exceeds this many pixels, e.g. 1920 (0 = off)")
+		statusAddr    = flag.String("status-addr", "", "Serve a read-only JSON status document at http://ADDR/status, e.g. 127.0.0.1:7777")
+
+		// The passphrase itself is never accepted as a flag value: it would
+		// be visible to other users via ps.
+		passEnv    = flag.String("passphrase-env", "", "Read the clipboard passphrase from this environment variable instead of the keychain")
+		passFile   = flag.String("passphrase-file", "", "Read the clipboard passphrase from this file instead of the keychain")
+		passFD     = flag.Int("passphrase-fd", -1, "Read the clipboard passphrase from this inherited file descriptor instead of the keychain")
+		passPrompt = flag.Bool("passphrase-prompt", false, "Prompt for the clipboard passphrase on the terminal instead of using the keychain")
 	)
+	var excludePatterns []string
+	flag.Func("exclude-pattern", "Never publish text matching this regexp, e.g. '^sk-[A-Za-z0-9]{20,}$' (repeatable)", func(p string) error {
+		excludePatterns = append(excludePatterns, p)
+		return nil
+	})
 	flag.Parse()
 
+	// Each of these picks what the process does; refuse to guess which of
+	// several was meant.
+	modes := 0
+	for _, set := range []bool{*testConnect, *send, *recv, *once, *paste, *tray} {
+		if set {
+			modes++
+		}
+	}
+	if modes > 1 {
+		fmt.Fprintln(os.Stderr, "Only one of -test-connect, -send, -recv, -once, -paste and -tray may be given.")
+		flag.Usage()
+		os.Exit(2)
+	}
+
 	if *showVer {
 		fmt.Printf("paperclip v%s\n", version)
 		os.Exit(0)
 	}
 
+	if *systemd {
+		path, err := ui.WriteSystemdUnit()
+		if err != nil {
+			log.Fatalf("Failed to write systemd unit: %v", err)
+		}
+		fmt.Printf("Wrote %s\n\nTo start paperclip now and at every login:\n\n", path)
+		fmt.Println("  systemctl --user daemon-reload")
+		fmt.Println("  systemctl --user enable --now paperclip.service")
+		os.Exit(0)
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		log.Printf("Warning: could not load config (%v), using defaults", err)
@@ -40,9 +112,58 @@ func main() {
 	if *pollMs != 0 {
 		cfg.PollMs = *pollMs
 	}
+	if *coalesceMs != 0 {
+		cfg.CoalesceMs = *coalesceMs
+	}
 	if *verbose {
 		cfg.Verbose = true
 	}
+	if *logFormat != "" {
+		cfg.LogFormat = *logFormat
+	}
+	if *hashAlgo != "" {
+		cfg.Hash = *hashAlgo
+	}
+	if *hotkeySpec != "" {
+		cfg.Hotkey = *hotkeySpec
+	}
+	if *syncOnConnect {
+		cfg.SyncOnConnect = true
+	}
+	if *compress {
+		cfg.Compress = true
+	}
+	if *allowFetch {
+		cfg.AllowFetch = true
+	}
+	if *dryRun {
+		cfg.DryRun = true
+	}
+	if *syncConcealed {
+		cfg.SyncConcealed = true
+	}
+	cfg.ExcludePatterns = append(cfg.ExcludePatterns, excludePatterns...)
+	if *inboundRate != 0 {
+		cfg.InboundRate = *inboundRate
+	}
+	if *persistHash {
+		cfg.PersistHash = true
+	}
+	if *statusAddr != "" {
+		cfg.StatusAddr = *statusAddr
+	}
+	if *maxImageDim != 0 {
+		cfg.MaxImageDim = *maxImageDim
+	}
+	if *jpegQuality != 0 {
+		cfg.JPEGQuality = *jpegQuality
+	}
+	if *noImage {
+		cfg.NoImage = true
+	}
+	if *syncTypes != "" {
+		cfg.SyncTypes = strings.Split(*syncTypes, ",")
+	}
 
 	// Re-validate after CLI flag overrides: a flag like --poll=-1 could produce
 	// an invalid value that wasn't present in the config file.
@@ -50,6 +171,25 @@ func main() {
 		log.Fatalf("Configuration error: %v", err)
 	}
 
+	if err := clipboard.SetHashAlgorithm(cfg.Hash); err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
+	if _, err := contentTypes(cfg.SyncTypes); err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
+
+	var hk hotkey.Hotkey
+	if cfg.Hotkey != "" {
+		if hk, err = hotkey.Parse(cfg.Hotkey); err != nil {
+			log.Fatalf("Configuration error: %v", err)
+		}
+	}
+
+	secret := relay.SecretSource{Env: *passEnv, File: *passFile, FD: *passFD, Prompt: *passPrompt}
+	if err := secret.Validate(); err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
+
 	// Resolve Ably API key: keychain → env var (for CI/scripting).
 	apiKey, keychainErr := relay.GetAPIKey()
 	if keychainErr != nil {
@@ -62,23 +202,87 @@ func main() {
 		rooms := strings.Split(*clipboardName, ",")
 		cfg.Relay.Clipboards = nil
 		for _, r := range rooms {
-			r = strings.TrimSpace(r)
-			if r != "" {
-				cfg.Relay.Clipboards = append(cfg.Relay.Clipboards, config.Clipboard{Name: r, Enabled: true})
+			if c := parseClipboardSpec(r); c.Name != "" {
+				cfg.Relay.Clipboards = append(cfg.Relay.Clipboards, c)
 			}
 		}
 	}
 
-	// Default to tray mode when the binary name contains "tray"
-	// (e.g. paperclip-tray.exe) so double-clicking it just works.
-	if *tray || strings.Contains(strings.ToLower(os.Args[0]), "tray") {
-		runTray(cfg)
+	if *testConnect {
+		runTestConnect(cfg, apiKey, secret)
+	} else if *send {
+		runSend(cfg, apiKey, secret, *sendImage)
+	} else if *recv {
+		runRecv(cfg, apiKey, secret, *pasteOut)
+	} else if *once {
+		runOnce(cfg, apiKey, secret)
+	} else if *paste {
+		runPaste(cfg, apiKey, secret, *pasteOut)
+	} else if *tray || strings.Contains(strings.ToLower(os.Args[0]), "tray") {
+		// Default to tray mode when the binary name contains "tray"
+		// (e.g. paperclip-tray.exe) so double-clicking it just works.
+		runTray(cfg, hk, secret)
 	} else {
-		runDaemon(cfg, apiKey)
+		runDaemon(cfg, apiKey, secret, hk, *clipboardName != "")
+	}
+}
+
+// parseClipboardSpec parses one -clipboard entry: a name, optionally
+// prefixed with "recv:" (receive only) or "send:" (send only).
+func parseClipboardSpec(spec string) config.Clipboard {
+	c := config.Clipboard{Enabled: true}
+	spec = strings.TrimSpace(spec)
+	for _, dir := range []string{config.DirectionRecv, config.DirectionSend} {
+		if name, ok := strings.CutPrefix(spec, dir+":"); ok {
+			c.Direction, spec = dir, name
+			break
+		}
+	}
+	c.Name = strings.TrimSpace(spec)
+	return c
+}
+
+// listenHotkey starts the explicit-push hotkey listener when one is
+// configured and returns its event channel, or nil when hotkey mode is off.
+// Where hotkeys are unsupported it warns and returns a channel that never
+// fires: the user asked not to publish automatically, so falling back to
+// automatic sync would be the wrong way to fail.
+func listenHotkey(hk hotkey.Hotkey, logger *log.Logger) <-chan struct{} {
+	if hk.Key == 0 {
+		return nil
+	}
+	src, err := hotkey.Listen(hk)
+	if err != nil {
+		logger.Printf("WARNING: hotkey %s unavailable (%v) — clipboard will be received but not published", hk, err)
+		return make(chan struct{})
 	}
+	logger.Printf("Explicit-push mode: press %s to publish the clipboard", hk)
+	return src.Events()
 }
 
-func startRelay(cfg *config.Config, apiKey string, cb *clipboard.Clipboard, logger *log.Logger, verbose bool) *relay.Relay {
+// serveStatus starts the read-only status endpoint in the background when
+// addr is set. A listen failure is logged but does not stop syncing.
+func serveStatus(addr string, current func() *relay.Relay, logger *log.Logger) {
+	if addr == "" {
+		return
+	}
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           relay.StatusHandler(current),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		logger.Printf("Status endpoint: http://%s/status", addr)
+		if err := srv.ListenAndServe(); err != nil {
+			logger.Printf("Status endpoint stopped: %v", err)
+		}
+	}()
+}
+
+// configureRelay creates a relay for the enabled clipboards with the configured
+// per-clipboard and publish settings applied, or returns nil if there is
+// nothing to relay.
+func configureRelay(cfg *config.Config, apiKey string, cb *clipboard.Clipboard, logger *log.Logger, verbose bool, passphrase relay.PassphraseFunc) *relay.Relay {
 	enabledClipboards := cfg.Relay.EnabledClipboards()
 	if apiKey == "" || len(enabledClipboards) == 0 {
 		return nil
@@ -89,16 +293,32 @@ func startRelay(cfg *config.Config, apiKey string, cb *clipboard.Clipboard, logg
 		clipboardNames = append(clipboardNames, r.Name)
 	}
 
-	r, err := relay.New(apiKey, clipboardNames, cb, logger, verbose)
+	r, err := relay.New(apiKey, clipboardNames, cb, logger, verbose, passphrase)
 	if err != nil {
 		logger.Printf("Failed to create relay: %v", err)
 		return nil
 	}
 
-	if err := r.Start(cfg.PollMs); err != nil {
-		logger.Printf("Failed to start relay: %v", err)
-		r.Stop() // close the Ably connection; Start may have left partial subscriptions
-		return nil
+	for _, c := range enabledClipboards {
+		switch c.Direction {
+		case config.DirectionSend:
+			r.SetDirection(c.Name, relay.SendOnly)
+		case config.DirectionRecv:
+			r.SetDirection(c.Name, relay.ReceiveOnly)
+		}
+	}
+	r.SetCompression(cfg.Compress)
+	r.SetFetch(cfg.AllowFetch)
+	types, _ := contentTypes(cfg.SyncTypes) // validated at startup
+	r.SetSyncTypes(types)
+	r.SetMaxImageDimension(cfg.MaxImageDim)
+	r.SetJPEGQuality(cfg.JPEGQuality)
+	r.SetDryRun(cfg.DryRun)
+	r.SetExcludePatterns(cfg.ExcludeRegexps())
+	r.SetSyncConcealed(cfg.SyncConcealed)
+	r.SetCoalesce(time.Duration(cfg.CoalesceMs) * time.Millisecond)
+	if cfg.InboundRate != 0 {
+		r.SetInboundRateLimit(cfg.InboundRate)
 	}
 
 	// Apply hub publish filter from config.
@@ -109,9 +329,78 @@ func startRelay(cfg *config.Config, apiKey string, cb *clipboard.Clipboard, logg
 	return r
 }
 
-func runTray(cfg *config.Config) {
-	logger := log.New(os.Stdout, "[paperclip] ", log.LstdFlags)
+func startRelay(cfg *config.Config, apiKey string, cb *clipboard.Clipboard, logger *log.Logger, verbose bool, passphrase relay.PassphraseFunc, trigger <-chan struct{}) *relay.Relay {
+	r := configureRelay(cfg, apiKey, cb, logger, verbose, passphrase)
+	if r == nil {
+		return nil
+	}
+
+	if trigger != nil {
+		r.PublishOn(trigger)
+	}
+	r.SetSyncOnConnect(cfg.SyncOnConnect)
+
+	if err := r.Start(cfg.PollMs); err != nil {
+		logger.Printf("Failed to start relay: %v", err)
+		r.Stop() // close the Ably connection; Start may have left partial subscriptions
+		return nil
+	}
+
+	return r
+}
+
+// contentTypes parses the sync_types setting.
+func contentTypes(names []string) ([]clipboard.ContentType, error) {
+	var types []clipboard.ContentType
+	for _, name := range names {
+		t, err := clipboard.ParseContentType(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// newLogger returns the process logger writing to w in the configured
+// format.
+func newLogger(cfg *config.Config, w io.Writer) *log.Logger {
+	if cfg.LogFormat == "json" {
+		return log.New(logging.NewJSONWriter(w), "", 0)
+	}
+	return log.New(w, "[paperclip] ", log.LstdFlags)
+}
+
+// newClipboard creates the clipboard backend with the configured read
+// priority and last-hash persistence applied.
+func newClipboard(cfg *config.Config, logger *log.Logger) *clipboard.Clipboard {
 	cb := clipboard.New(logger)
+	if err := cb.SetPasteboardTypes(cfg.PasteboardTypes); err != nil {
+		logger.Fatalf("Configuration error: %v", err)
+	}
+	cb.SetNoImages(cfg.NoImage)
+	if cfg.PersistHash {
+		dir, err := config.Dir()
+		if err == nil {
+			err = cb.PersistLastHash(filepath.Join(dir, "last_hash"))
+		}
+		if err != nil {
+			logger.Printf("WARNING: not persisting the last clipboard hash: %v", err)
+		}
+	}
+	return cb
+}
+
+func runTray(cfg *config.Config, hk hotkey.Hotkey, secret relay.SecretSource) {
+	logger := newLogger(cfg, os.Stdout)
+	cb := newClipboard(cfg, logger)
+	passphrase := readPassphrase(secret, logger)
+	trigger := listenHotkey(hk, logger)
+
+	// The tray replaces its relay when settings change; the status endpoint
+	// always reports the latest one.
+	var current atomic.Pointer[relay.Relay]
+	serveStatus(cfg.StatusAddr, current.Load, logger)
 
 	// newRelay reads the API key from keychain each time so that key updates
 	// via the tray take effect without restarting the process.
@@ -120,7 +409,9 @@ func runTray(cfg *config.Config) {
 		if key == "" {
 			key = os.Getenv("PAPERCLIP_ABLY_KEY")
 		}
-		return startRelay(cfg, key, cb, logger, cfg.Verbose)
+		r := startRelay(cfg, key, cb, logger, cfg.Verbose, passphrase, trigger)
+		current.Store(r)
+		return r
 	}
 
 	logger.Println("Starting paperclip (tray mode)")
@@ -129,24 +420,295 @@ func runTray(cfg *config.Config) {
 	}, version)
 }
 
-func runDaemon(cfg *config.Config, apiKey string) {
-	logger := log.New(os.Stdout, "[paperclip] ", log.LstdFlags)
+// readPassphrase resolves an explicit passphrase source, or returns nil to
+// use the credential store.
+func readPassphrase(secret relay.SecretSource, logger *log.Logger) relay.PassphraseFunc {
+	if !secret.IsSet() {
+		return nil
+	}
+	passphrase, err := secret.PassphraseFunc()
+	if err != nil {
+		logger.Fatalf("Failed to read passphrase: %v", err)
+	}
+	return passphrase
+}
+
+// runOnce publishes the current clipboard a single time and exits, for
+// scripts and hotkey tools that don't want a running daemon.
+func runOnce(cfg *config.Config, apiKey string, secret relay.SecretSource) {
+	logger := newLogger(cfg, os.Stderr)
+
+	r := configureRelay(cfg, apiKey, newClipboard(cfg, logger), logger, cfg.Verbose, readPassphrase(secret, logger))
+	if r == nil {
+		logger.Fatal("No relay configured. Set up an Ably API key and clipboards via --tray, or set PAPERCLIP_ABLY_KEY.")
+	}
+	n, err := r.PublishOnce()
+	r.Stop()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if n == 0 {
+		logger.Fatal("Nothing was published: no clipboard accepted the message")
+	}
+	if cfg.Verbose {
+		logger.Printf("Published to %d clipboard(s)", n)
+	}
+}
+
+// testConnectTimeout is how long -test-connect waits for every clipboard to
+// attach.
+const testConnectTimeout = 15 * time.Second
+
+// runTestConnect attaches to each enabled clipboard without polling or
+// touching the local clipboard, prints whether it worked and the key
+// fingerprint to compare with other machines, and exits non-zero if any
+// clipboard failed.
+func runTestConnect(cfg *config.Config, apiKey string, secret relay.SecretSource) {
+	logger := newLogger(cfg, os.Stderr)
+
+	r := configureRelay(cfg, apiKey, newClipboard(cfg, logger), logger, cfg.Verbose, readPassphrase(secret, logger))
+	if r == nil {
+		logger.Fatal("No relay configured. Set up an Ably API key and clipboards via --tray, or set PAPERCLIP_ABLY_KEY.")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), testConnectTimeout)
+	results := r.Check(ctx)
+	cancel()
+	r.Stop()
+
+	failed := false
+	checked := make(map[string]bool, len(results))
+	for _, res := range results {
+		checked[res.Name] = true
+		if res.Err != nil {
+			fmt.Printf("FAIL  %s: %v\n", res.Name, res.Err)
+			failed = true
+			continue
+		}
+		fmt.Printf("ok    %s  %s\n", res.Name, res.Fingerprint)
+	}
+	// New skips clipboards it has no passphrase for; it has logged why.
+	for _, c := range cfg.Relay.EnabledClipboards() {
+		if !checked[c.Name] {
+			fmt.Printf("FAIL  %s: no passphrase\n", c.Name)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// pasteTimeout is how long -paste waits for a running machine to answer.
+const pasteTimeout = 10 * time.Second
+
+// runPaste fetches the current clipboard of the machines on the first
+// enabled clipboard and writes it to stdout or outPath, leaving the local
+// clipboard untouched.
+func runPaste(cfg *config.Config, apiKey string, secret relay.SecretSource, outPath string) {
+	logger := newLogger(cfg, os.Stderr)
+
+	r := configureRelay(cfg, apiKey, newClipboard(cfg, logger), logger, cfg.Verbose, readPassphrase(secret, logger))
+	if r == nil {
+		logger.Fatal("No relay configured. Set up an Ably API key and clipboards via --tray, or set PAPERCLIP_ABLY_KEY.")
+	}
+	name := r.ClipboardNames()[0]
+
+	ctx, cancel := context.WithTimeout(context.Background(), pasteTimeout)
+	content, err := r.Fetch(ctx, name)
+	cancel()
+	r.Stop()
+	if err != nil {
+		logger.Fatalf("Paste from clipboard '%s' failed: %v", name, err)
+	}
+
+	writeContent(content, outPath, logger)
+}
+
+// writeContent writes received content to outPath, or to stdout if outPath
+// is empty. Images must go to a file.
+func writeContent(content *clipboard.Content, outPath string, logger *log.Logger) {
+	var err error
+	switch {
+	case outPath != "":
+		err = os.WriteFile(outPath, content.Data, 0600)
+	case content.Type == clipboard.TypeImage:
+		logger.Fatal("Clipboard holds an image; use -out FILE to save it")
+	default:
+		_, err = os.Stdout.Write(content.Data)
+	}
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
+
+// pngSignature starts every PNG file.
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// runSend publishes stdin once to every clipboard this machine sends to, as
+// text or, with image, as a PNG. The local clipboard is not read or written.
+func runSend(cfg *config.Config, apiKey string, secret relay.SecretSource, image bool) {
+	logger := newLogger(cfg, os.Stderr)
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		logger.Fatalf("Failed to read stdin: %v", err)
+	}
+	content := &clipboard.Content{Type: clipboard.TypeText, Data: data, Hash: clipboard.HashData(data)}
+	if image {
+		if !bytes.HasPrefix(data, pngSignature) {
+			logger.Fatal("-image expects a PNG on stdin")
+		}
+		content.Type = clipboard.TypeImage
+	}
+
+	r := configureRelay(cfg, apiKey, newClipboard(cfg, logger), logger, cfg.Verbose, readPassphrase(secret, logger))
+	if r == nil {
+		logger.Fatal("No relay configured. Set up an Ably API key and clipboards via --tray, or set PAPERCLIP_ABLY_KEY.")
+	}
+	n := r.PublishContent(content)
+	r.Stop()
+	if n == 0 {
+		logger.Fatal("Nothing was published: no clipboard accepted the message")
+	}
+	if cfg.Verbose {
+		logger.Printf("Published to %d clipboard(s)", n)
+	}
+}
+
+// runRecv waits, until interrupted, for the next item published on the
+// first enabled clipboard and writes it to stdout or outPath. The local
+// clipboard is not written.
+func runRecv(cfg *config.Config, apiKey string, secret relay.SecretSource, outPath string) {
+	logger := newLogger(cfg, os.Stderr)
+
+	r := configureRelay(cfg, apiKey, newClipboard(cfg, logger), logger, cfg.Verbose, readPassphrase(secret, logger))
+	if r == nil {
+		logger.Fatal("No relay configured. Set up an Ably API key and clipboards via --tray, or set PAPERCLIP_ABLY_KEY.")
+	}
+	name := r.ClipboardNames()[0]
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	content, err := r.Receive(ctx, name)
+	stop()
+	r.Stop()
+	if err != nil {
+		logger.Fatalf("Receive from clipboard '%s' failed: %v", name, err)
+	}
+	writeContent(content, outPath, logger)
+}
+
+// shutdownTimeout bounds how long the daemon waits on SIGINT/SIGTERM for an
+// in-flight publish to finish before closing the connection anyway.
+const shutdownTimeout = 5 * time.Second
+
+func runDaemon(cfg *config.Config, apiKey string, secret relay.SecretSource, hk hotkey.Hotkey, fixedClipboards bool) {
+	out := os.Stdout
 	if !cfg.Verbose {
-		logger.SetOutput(os.Stderr)
+		out = os.Stderr
 	}
+	logger := newLogger(cfg, out)
 
-	cb := clipboard.New(logger)
-	r := startRelay(cfg, apiKey, cb, logger, cfg.Verbose)
+	cb := newClipboard(cfg, logger)
+	passphrase := readPassphrase(secret, logger)
+	trigger := listenHotkey(hk, logger)
+	r := startRelay(cfg, apiKey, cb, logger, cfg.Verbose, passphrase, trigger)
 
 	if r == nil {
 		logger.Fatal("No relay configured. Set up an Ably API key and clipboards via --tray, or set PAPERCLIP_ABLY_KEY.")
 	}
+	var current atomic.Pointer[relay.Relay]
+	current.Store(r)
+	serveStatus(cfg.StatusAddr, current.Load, logger)
 
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	logger.Println("Starting paperclip")
-	<-sigChan
+	for sig := range sigChan {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		if fixedClipboards {
+			logger.Println("SIGHUP: clipboards were set with -clipboard — nothing to reload")
+			continue
+		}
+		if nr := reloadClipboards(cfg, r, func() *relay.Relay {
+			return startRelay(cfg, apiKey, cb, logger, cfg.Verbose, passphrase, trigger)
+		}, logger); nr != nil {
+			r = nr
+			current.Store(r)
+		}
+	}
 	logger.Println("Shutting down...")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := r.Shutdown(ctx); err != nil {
+		logger.Printf("Shutdown did not finish within %s; in-flight sends were cancelled", shutdownTimeout)
+	}
+}
+
+// reloadClipboards re-reads the clipboard list and hub settings from
+// config.json and, if they changed, replaces the running relay using start.
+// It returns the new relay, or nil if the old one is still running. If the
+// new list cannot be started, the previous settings are restored.
+func reloadClipboards(cfg *config.Config, r *relay.Relay, start func() *relay.Relay, logger *log.Logger) *relay.Relay {
+	fresh, err := config.Load()
+	if err != nil {
+		logger.Printf("SIGHUP: could not reload config (%v) — keeping current clipboards", err)
+		return nil
+	}
+
+	added, removed := clipboardChanges(cfg.Relay.EnabledClipboards(), fresh.Relay.EnabledClipboards())
+	hubChanged := cfg.IsHub != fresh.IsHub || !slices.Equal(cfg.HubTargets, fresh.HubTargets)
+	if len(added) == 0 && len(removed) == 0 && !hubChanged {
+		logger.Println("SIGHUP: clipboards unchanged")
+		return nil
+	}
+	logger.Printf("SIGHUP: reloading clipboards (added %v, removed %v)", added, removed)
+
+	prev := *cfg
+	cfg.Relay.Clipboards = fresh.Relay.Clipboards
+	cfg.IsHub, cfg.HubTargets = fresh.IsHub, fresh.HubTargets
+
 	r.Stop()
+	if nr := start(); nr != nil {
+		return nr
+	}
+	logger.Println("SIGHUP: new clipboards could not be started — restoring previous ones")
+	cfg.Relay.Clipboards = prev.Relay.Clipboards
+	cfg.IsHub, cfg.HubTargets = prev.IsHub, prev.HubTargets
+	if nr := start(); nr != nil {
+		return nr
+	}
+	logger.Fatal("Failed to restart relay after reload")
+	return nil
+}
+
+// clipboardChanges lists the clipboards added and removed between two
+// lists. A clipboard whose direction changed appears in both.
+func clipboardChanges(old, updated []config.Clipboard) (added, removed []string) {
+	key := func(c config.Clipboard) string {
+		if c.Direction != "" {
+			return c.Direction + ":" + c.Name
+		}
+		return c.Name
+	}
+	inOld := make(map[string]bool, len(old))
+	for _, c := range old {
+		inOld[key(c)] = true
+	}
+	inNew := make(map[string]bool, len(updated))
+	for _, c := range updated {
+		inNew[key(c)] = true
+		if !inOld[key(c)] {
+			added = append(added, key(c))
+		}
+	}
+	for _, c := range old {
+		if !inNew[key(c)] {
+			removed = append(removed, key(c))
+		}
+	}
+	return added, removed
 }
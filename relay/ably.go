@@ -11,12 +11,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"regexp"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ably/ably-go/ably"
 	"github.com/mindmorass/paperclip/clipboard"
+	"github.com/mindmorass/paperclip/logging"
 )
 
 const (
@@ -35,11 +37,18 @@ const (
 	maxPlaintextBytes = 47 * 1024
 )
 
-// ClipboardStatus represents the state of a single relay room.
+// ClipboardStatus represents the state of a single relay room. Byte counts
+// are plaintext content bytes, before encryption.
 type ClipboardStatus struct {
-	Name      string
-	Connected bool
-	Encrypted bool
+	Name          string `json:"name"`
+	Connected     bool   `json:"connected"`
+	Encrypted     bool   `json:"encrypted"`
+	ItemsSent     uint64 `json:"items_sent"`
+	ItemsReceived uint64 `json:"items_received"`
+	BytesSent     uint64 `json:"bytes_sent"`
+	BytesReceived uint64 `json:"bytes_received"`
+	DedupDropped  uint64 `json:"dedup_dropped"`
+	AuthFailures  uint64 `json:"auth_failures"`
 }
 
 // ablyMsg is the typed wire format for messages published to Ably channels.
@@ -56,10 +65,12 @@ type ablyMsg struct {
 // clipboardSyncer abstracts clipboard operations so the relay is testable
 // without touching the real OS clipboard.
 type clipboardSyncer interface {
+	ChangeToken() (uint64, error)
 	Read() (*clipboard.Content, error)
 	Write(*clipboard.Content) error
 	HasChanged(string) bool
 	SetLastHash(string)
+	MarkSent(string)
 }
 
 // Relay syncs clipboard data through Ably pub/sub across multiple rooms.
@@ -67,7 +78,7 @@ type Relay struct {
 	client    *ably.Realtime
 	rooms     []*roomSub
 	clipboard clipboardSyncer
-	logger    *log.Logger
+	logger    logging.Logger
 	verbose   bool
 	sender    string
 
@@ -82,6 +93,92 @@ type Relay struct {
 
 	filterMu      sync.RWMutex
 	publishFilter map[string]bool // nil = publish to all; non-nil = hub mode with selected targets
+
+	trigger       <-chan struct{} // non-nil = explicit-push mode; see PublishOn
+	syncOnConnect bool            // ask Ably to replay the latest message on attach
+	compress      bool            // gzip payloads when smaller; see SetCompression
+	fetch         bool            // send and answer fetch requests; see SetFetch
+
+	syncTypes   map[clipboard.ContentType]bool // nil = sync every type; see SetSyncTypes
+	maxImageDim int                            // 0 = send images as copied; see SetMaxImageDimension
+	dryRun      bool                           // log instead of publishing or writing; see SetDryRun
+	exclude     []*regexp.Regexp               // text matching any is never published; see SetExcludePatterns
+	concealed   bool                           // publish content marked as secret; see SetSyncConcealed
+	coalesce    time.Duration                  // quiet period before publishing a change; see SetCoalesce
+	jpegQuality int                            // 0 = always send PNG; see SetJPEGQuality
+
+	onContent func(*clipboard.Content) bool // veto for received content; see OnContent
+	onState   func(connected bool)          // see OnConnectionState
+
+	// Test hooks; nil means the wall clock and crypto/rand.
+	nowFunc func() time.Time
+	rand    io.Reader
+}
+
+// SetSyncOnConnect makes each clipboard channel replay its most recent
+// message when it attaches (Ably "rewind"), so a node that was offline picks
+// up the latest copy made elsewhere instead of waiting for the next one.
+// Ably only retains rewindable messages for a limited time (two minutes
+// without channel persistence), and the replay window still applies.
+// Must be called before Start.
+func (r *Relay) SetSyncOnConnect(enabled bool) {
+	r.syncOnConnect = enabled
+}
+
+func (r *Relay) channelOptions() []ably.ChannelOption {
+	if !r.syncOnConnect {
+		return nil
+	}
+	return []ably.ChannelOption{ably.ChannelWithParams("rewind", "1")}
+}
+
+func (r *Relay) now() time.Time {
+	if r.nowFunc != nil {
+		return r.nowFunc()
+	}
+	return time.Now()
+}
+
+func (r *Relay) randReader() io.Reader {
+	if r.rand != nil {
+		return r.rand
+	}
+	return rand.Reader
+}
+
+// OnContent registers fn to be called with each item received from a
+// clipboard, after decryption and dedup and before it is written. Returning
+// false drops the item. fn runs on the Ably delivery goroutine, so it should
+// return quickly. Must be called before Start.
+func (r *Relay) OnContent(fn func(*clipboard.Content) bool) {
+	r.onContent = fn
+}
+
+// OnConnectionState registers fn to be called whenever the Ably connection
+// comes up (true) or is lost (false). Must be called before Start.
+func (r *Relay) OnConnectionState(fn func(connected bool)) {
+	r.onState = fn
+}
+
+// connectionTransition reports whether change moves the connection into or
+// out of the connected state, and which way.
+func connectionTransition(change ably.ConnectionStateChange) (connected, ok bool) {
+	switch {
+	case change.Current == ably.ConnectionStateConnected && change.Previous != ably.ConnectionStateConnected:
+		return true, true
+	case change.Previous == ably.ConnectionStateConnected && change.Current != ably.ConnectionStateConnected:
+		return false, true
+	}
+	return false, false
+}
+
+// PublishOn switches the relay to explicit-push mode: the poller no longer
+// publishes clipboard changes, and instead the current clipboard is read and
+// published once for each value received on trigger (e.g. a global hotkey).
+// A trigger that never fires (e.g. where hotkeys are unsupported) disables
+// publishing entirely while still receiving. Must be called before Start.
+func (r *Relay) PublishOn(trigger <-chan struct{}) {
+	r.trigger = trigger
 }
 
 // SetPublishFilter sets which clipboards this relay publishes to.
@@ -119,14 +216,139 @@ func (r *Relay) LastSyncAt() time.Time {
 
 func (r *Relay) recordSync() {
 	r.syncMu.Lock()
-	r.lastSyncAt = time.Now()
+	r.lastSyncAt = r.now()
 	r.syncMu.Unlock()
 }
 
+// roomChannel is the subset of *ably.RealtimeChannel the relay uses, so the
+// publish and subscribe paths can be exercised in tests without Ably.
+type roomChannel interface {
+	SubscribeAll(ctx context.Context, handle func(*ably.Message)) (func(), error)
+	Publish(ctx context.Context, name string, data interface{}) error
+}
+
 type roomSub struct {
-	name    string
-	channel *ably.RealtimeChannel
-	encKey  []byte // AES-256-GCM key derived from passphrase
+	name      string
+	channel   roomChannel
+	encKey    []byte // AES-256-GCM key derived from passphrase
+	direction Direction
+	stats     roomStats
+	inbound   rateLimiter // bounds clipboard writes from this room
+}
+
+// Direction restricts which way a clipboard syncs.
+type Direction int
+
+const (
+	Both        Direction = iota
+	SendOnly              // publish local copies; ignore incoming
+	ReceiveOnly           // apply incoming; never publish local copies
+)
+
+// SetSyncTypes limits syncing to the given content types, in both
+// directions: other local content is not published and other incoming
+// content is dropped. An empty list syncs everything. Must be called before
+// Start.
+func (r *Relay) SetSyncTypes(types []clipboard.ContentType) {
+	if len(types) == 0 {
+		r.syncTypes = nil
+		return
+	}
+	r.syncTypes = make(map[clipboard.ContentType]bool, len(types))
+	for _, t := range types {
+		r.syncTypes[t] = true
+	}
+}
+
+// SetExcludePatterns stops text matching any of patterns from being
+// published, e.g. to keep passwords and API tokens local. Only text content
+// is checked. Must be called before Start.
+func (r *Relay) SetExcludePatterns(patterns []*regexp.Regexp) {
+	r.exclude = patterns
+}
+
+// SetSyncConcealed allows publishing content the source application marked
+// as secret, such as passwords copied from a password manager. By default
+// it stays on this machine. Must be called before Start.
+func (r *Relay) SetSyncConcealed(enabled bool) {
+	r.concealed = enabled
+}
+
+// excluded reports whether content must not leave this machine, logging
+// why but never the content.
+func (r *Relay) excluded(content *clipboard.Content) bool {
+	if content.Concealed && !r.concealed {
+		if r.verbose {
+			r.logger.Printf("Not publishing %s marked as concealed by its source", content.Type)
+		}
+		return true
+	}
+	if content.Type != clipboard.TypeText {
+		return false
+	}
+	for _, re := range r.exclude {
+		if re.Match(content.Data) {
+			r.logger.Printf("Not publishing text matching exclude pattern %q", re.String())
+			return true
+		}
+	}
+	return false
+}
+
+// SetCoalesce makes the poller publish only the last of a burst of local
+// changes: a change is held until the clipboard has been unchanged for
+// quiet, and a newer change replaces it. Zero publishes every change
+// immediately. Must be called before Start.
+func (r *Relay) SetCoalesce(quiet time.Duration) {
+	r.coalesce = quiet
+}
+
+// SetDryRun makes the relay run change detection, decryption and dedup as
+// usual but only log what it would publish or write to the clipboard. Must
+// be called before Start.
+func (r *Relay) SetDryRun(enabled bool) {
+	r.dryRun = enabled
+}
+
+// SetMaxImageDimension makes the relay downscale outgoing images so neither
+// side exceeds px pixels. Receivers get whatever the sender sent. Zero
+// disables scaling. Must be called before Start.
+func (r *Relay) SetMaxImageDimension(px int) {
+	r.maxImageDim = px
+}
+
+// syncsType reports whether content of type t is synced.
+func (r *Relay) syncsType(t clipboard.ContentType) bool {
+	return r.syncTypes == nil || r.syncTypes[t]
+}
+
+// SetInboundRateLimit bounds how often content received on each clipboard
+// is written to the local clipboard to perSecond, with short bursts allowed.
+// Content over the limit is dropped with a warning. Zero or less removes the
+// limit. Must be called before Start.
+func (r *Relay) SetInboundRateLimit(perSecond float64) {
+	for _, room := range r.rooms {
+		room.inbound.set(perSecond, max(defaultInboundBurst, int(2*perSecond)))
+	}
+}
+
+// SetDirection restricts the named clipboard to one-way sync, e.g.
+// ReceiveOnly on a locked-down machine that must never leak its own
+// clipboard. Must be called before Start.
+func (r *Relay) SetDirection(name string, d Direction) {
+	for _, room := range r.rooms {
+		if room.name == name {
+			room.direction = d
+		}
+	}
+}
+
+// roomStats counts traffic on one room; safe for concurrent use.
+type roomStats struct {
+	itemsSent, itemsReceived atomic.Uint64
+	bytesSent, bytesReceived atomic.Uint64
+	dedupDropped             atomic.Uint64 // inbound content we already held
+	authFailures             atomic.Uint64 // inbound messages failing HMAC or decryption
 }
 
 // New creates a new Ably relay connected to multiple rooms.
@@ -134,7 +356,12 @@ type roomSub struct {
 // are skipped. Returns an error if no rooms have passphrases.
 // cb accepts any clipboardSyncer implementation; pass a *clipboard.Clipboard
 // for production use or a test double in unit tests.
-func New(apiKey string, roomNames []string, cb clipboardSyncer, logger *log.Logger, verbose bool) (*Relay, error) {
+// passphrase looks up each room's passphrase; nil means the system keychain.
+func New(apiKey string, roomNames []string, cb clipboardSyncer, logger logging.Logger, verbose bool, passphrase PassphraseFunc) (*Relay, error) {
+	if passphrase == nil {
+		passphrase = GetPassphrase
+	}
+
 	if verbose {
 		logger.Printf("Ably key: [configured]")
 		logger.Printf("Ably clipboards: %v", roomNames)
@@ -150,15 +377,15 @@ func New(apiKey string, roomNames []string, cb clipboardSyncer, logger *log.Logg
 
 	var rooms []*roomSub
 	for _, name := range roomNames {
-		room := &roomSub{
-			name:    name,
-			channel: client.Channels.Get(name),
-		}
+		// The Ably channel itself is created in Start, once channel options
+		// such as SetSyncOnConnect are known.
+		room := &roomSub{name: name}
+		room.inbound.set(defaultInboundRate, defaultInboundBurst)
 
 		// Passphrase is required — skip rooms without one.
-		if passphrase, err := GetPassphrase(name); err == nil && passphrase != "" {
-			room.encKey = deriveKey(passphrase, name)
-			logger.Printf("Encryption enabled for clipboard '%s'", name)
+		if pass, err := passphrase(name); err == nil && pass != "" {
+			room.encKey = deriveKey(pass, name)
+			logger.Printf("Encryption enabled for clipboard '%s' (key %s)", name, keyFingerprint(room.encKey))
 			rooms = append(rooms, room)
 		} else if err != nil {
 			// Distinguish a keychain access failure (locked keychain, permission
@@ -206,7 +433,19 @@ func (r *Relay) Start(pollMs int) error {
 		return fmt.Errorf("poll interval must be positive, got %d ms", pollMs)
 	}
 
+	if r.onState != nil && r.client != nil {
+		off := r.client.Connection.OnAll(func(change ably.ConnectionStateChange) {
+			if connected, ok := connectionTransition(change); ok {
+				r.onState(connected)
+			}
+		})
+		context.AfterFunc(r.ctx, off)
+	}
+
 	for _, room := range r.rooms {
+		if room.channel == nil {
+			room.channel = r.client.Channels.Get(room.name, r.channelOptions()...)
+		}
 		rm := room // capture for closure
 		_, err := room.channel.SubscribeAll(r.ctx, func(msg *ably.Message) {
 			r.handleMessage(rm, msg)
@@ -221,7 +460,11 @@ func (r *Relay) Start(pollMs int) error {
 	}
 
 	r.wg.Add(1)
-	go r.pollAndPublish(time.Duration(pollMs) * time.Millisecond)
+	if r.trigger != nil {
+		go r.publishOnTrigger()
+	} else {
+		go r.pollAndPublish(time.Duration(pollMs) * time.Millisecond)
+	}
 
 	return nil
 }
@@ -233,12 +476,45 @@ func (r *Relay) Stop() {
 		r.cancel()
 		close(r.stopChan)
 		r.wg.Wait()
-		r.client.Close()
+		if r.client != nil {
+			r.client.Close()
+		}
+	})
+}
+
+// Shutdown stops the relay gracefully: it stops polling, lets a publish
+// already in flight (and any change held back by SetCoalesce) finish, then
+// closes the connection. If ctx expires first, the remaining work is
+// cancelled as in Stop and ctx's error is returned. Like Stop, only the
+// first call does anything.
+func (r *Relay) Shutdown(ctx context.Context) error {
+	var err error
+	r.stopOnce.Do(func() {
+		close(r.stopChan)
+		drained := make(chan struct{})
+		go func() {
+			r.wg.Wait()
+			close(drained)
+		}()
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		r.cancel()
+		<-drained
+		if r.client != nil {
+			r.client.Close()
+		}
 	})
+	return err
 }
 
 // Connected returns whether the Ably connection is active.
 func (r *Relay) Connected() bool {
+	if r.client == nil {
+		return false
+	}
 	return r.client.Connection.State() == ably.ConnectionStateConnected
 }
 
@@ -248,9 +524,15 @@ func (r *Relay) Status() []ClipboardStatus {
 	statuses := make([]ClipboardStatus, len(r.rooms))
 	for i, room := range r.rooms {
 		statuses[i] = ClipboardStatus{
-			Name:      room.name,
-			Connected: connected,
-			Encrypted: room.encKey != nil,
+			Name:          room.name,
+			Connected:     connected,
+			Encrypted:     room.encKey != nil,
+			ItemsSent:     room.stats.itemsSent.Load(),
+			ItemsReceived: room.stats.itemsReceived.Load(),
+			BytesSent:     room.stats.bytesSent.Load(),
+			BytesReceived: room.stats.bytesReceived.Load(),
+			DedupDropped:  room.stats.dedupDropped.Load(),
+			AuthFailures:  room.stats.authFailures.Load(),
 		}
 	}
 	return statuses
@@ -265,84 +547,170 @@ func (r *Relay) ClipboardNames() []string {
 	return names
 }
 
-func (r *Relay) handleMessage(room *roomSub, msg *ably.Message) {
+// inbound is an authenticated, decrypted message.
+type inbound struct {
+	typ    uint8 // content type, compression and reply flags cleared
+	data   []byte
+	sentAt time.Time
+	reply  bool // an answer to a fetch request; see typeFetchReply
+}
+
+// decodeMessage verifies, decrypts and unpacks a message from room. It
+// returns false, after logging why, for anything that should be dropped,
+// including our own messages.
+func (r *Relay) decodeMessage(room *roomSub, msg *ably.Message) (inbound, bool) {
 	rawJSON, ok := msg.Data.(string)
 	if !ok {
-		return
+		return inbound{}, false
 	}
 
 	var amsg ablyMsg
 	if err := json.Unmarshal([]byte(rawJSON), &amsg); err != nil {
-		return
+		return inbound{}, false
 	}
 
 	// Ignore our own messages.
 	if amsg.Sender == r.sender {
-		return
+		return inbound{}, false
 	}
 
 	// Verify HMAC — rejects injected messages from parties without the key.
 	if room.encKey == nil {
 		r.logger.Printf("ERROR: received message for clipboard '%s' with no encryption key — dropping", room.name)
-		return
+		return inbound{}, false
 	}
 	if !verifyMAC(room.encKey, amsg) {
-		r.logger.Printf("HMAC verification failed for clipboard '%s' (sender %s) — dropping message", room.name, amsg.Sender)
-		return
+		room.stats.authFailures.Add(1)
+		r.logger.Printf("HMAC verification failed for clipboard '%s' (sender %s) — dropping message; check the sender's key matches %s", room.name, amsg.Sender, keyFingerprint(room.encKey))
+		return inbound{}, false
 	}
 
 	raw, err := base64.StdEncoding.DecodeString(amsg.Data)
 	if err != nil {
 		r.logger.Printf("Failed to decode relay message: %v", err)
-		return
+		return inbound{}, false
 	}
 
 	// Decrypt — room name is AAD to prevent cross-room replay.
 	decrypted, err := decrypt(room.encKey, raw, []byte(room.name))
 	if err != nil {
+		room.stats.authFailures.Add(1)
 		r.logger.Printf("Failed to decrypt message from clipboard '%s': %v", room.name, err)
-		return
+		return inbound{}, false
 	}
 
 	// Extract and validate the 8-byte timestamp prepended by the sender.
 	if len(decrypted) < 8 {
 		r.logger.Printf("Decrypted payload too short from clipboard '%s' — dropping", room.name)
-		return
+		return inbound{}, false
 	}
 	msgTs := int64(binary.BigEndian.Uint64(decrypted[:8]))
 	plaintext := decrypted[8:]
 
-	delta := time.Now().Unix() - msgTs
+	delta := r.now().Unix() - msgTs
 	if delta < 0 {
 		delta = -delta
 	}
 	if delta > replayWindowSeconds {
 		r.logger.Printf("Replay rejected for clipboard '%s': message timestamp drift %ds exceeds %ds window", room.name, delta, replayWindowSeconds)
+		return inbound{}, false
+	}
+
+	contentType := amsg.Type
+	reply := contentType&typeFetchReply != 0
+	contentType &^= typeFetchReply
+	if contentType&typeCompressed != 0 {
+		contentType &^= typeCompressed
+		if plaintext, err = decompressPayload(plaintext); err != nil {
+			r.logger.Printf("Failed to decompress message from clipboard '%s': %v", room.name, err)
+			return inbound{}, false
+		}
+	}
+	if contentType == typeJPEG {
+		if plaintext, err = clipboard.JPEGToPNG(plaintext); err != nil {
+			r.logger.Printf("Failed to decode JPEG image from clipboard '%s': %v", room.name, err)
+			return inbound{}, false
+		}
+		contentType = uint8(clipboard.TypeImage)
+	}
+	return inbound{typ: contentType, data: plaintext, sentAt: time.Unix(msgTs, 0), reply: reply}, true
+}
+
+func (r *Relay) handleMessage(room *roomSub, msg *ably.Message) {
+	in, ok := r.decodeMessage(room, msg)
+	if !ok {
+		return
+	}
+	if in.typ == typeFetchRequest {
+		r.answerFetch(room, in)
+		return
+	}
+	if in.reply || room.direction == SendOnly {
+		return
+	}
+
+	contentType, plaintext := in.typ, in.data
+	if !clipboard.ContentType(contentType).Known() {
+		if r.verbose {
+			r.logger.Printf("Ignoring unsupported content type %#x from clipboard '%s'", contentType, room.name)
+		}
+		return
+	}
+	if !r.syncsType(clipboard.ContentType(contentType)) {
+		if r.verbose {
+			r.logger.Printf("Ignoring %s from clipboard '%s' (not in sync types)", clipboard.ContentType(contentType), room.name)
+		}
 		return
 	}
 
 	// Compute local hash so clipboard.Write sets the correct lastHash.
 	// This prevents re-publishing received content on the next poll cycle.
 	localHash := plaintextHash(plaintext)
+
+	// Skip content we already hold, e.g. a catch-up message replayed on
+	// connect or the same copy arriving from several senders. Rewriting it
+	// would churn the OS clipboard for nothing.
+	if !r.clipboard.HasChanged(localHash) {
+		room.stats.dedupDropped.Add(1)
+		return
+	}
+
+	if ok, first := room.inbound.allow(r.now()); !ok {
+		if first {
+			r.logger.Printf("WARNING: clipboard '%s' is receiving content faster than the inbound limit — dropping until it slows down", room.name)
+		}
+		return
+	}
+
 	content := &clipboard.Content{
-		Type: clipboard.ContentType(amsg.Type),
+		Type: clipboard.ContentType(contentType),
 		Data: plaintext,
 		Hash: localHash,
 	}
 
+	if r.onContent != nil && !r.onContent(content) {
+		if r.verbose {
+			r.logger.Printf("Dropped %s from clipboard '%s' (rejected by content hook)", content.Type, room.name)
+		}
+		return
+	}
+
+	if r.dryRun {
+		r.logger.Printf("Dry run: would write %s (%d bytes, hash %.12s) from clipboard '%s'", content.Type, len(plaintext), localHash, room.name)
+		return
+	}
+
 	if err := r.clipboard.Write(content); err != nil {
 		r.logger.Printf("Failed to write clipboard from relay: %v", err)
 		return
 	}
 
 	r.recordSync()
+	room.stats.itemsReceived.Add(1)
+	room.stats.bytesReceived.Add(uint64(len(plaintext)))
 
 	if r.verbose {
-		typeStr := "text"
-		if content.Type == clipboard.TypeImage {
-			typeStr = "image"
-		}
-		r.logger.Printf("Received %s (%d bytes) via clipboard '%s' (encrypted)", typeStr, len(plaintext), room.name)
+		r.logger.Printf("Received %s (%d bytes) via clipboard '%s' (encrypted)", content.Type, len(plaintext), room.name)
 	}
 }
 
@@ -352,99 +720,289 @@ func (r *Relay) pollAndPublish(interval time.Duration) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	// Where the OS offers a change counter, skip the read and hash entirely
+	// while it stands still. lastToken is only advanced after a successful
+	// read so a failed read is retried on the next tick.
+	var lastToken uint64
+	haveToken := false
+
+	// With coalescing, a change waits in pending until the clipboard has
+	// been quiet for r.coalesce; a newer change replaces it and restarts
+	// the wait, so only the last of a burst is published. Content received
+	// in the meantime supersedes it; see flushPending.
+	var pending *clipboard.Content
+	var pendingSince time.Time
+
 	for {
 		select {
 		case <-r.stopChan:
+			// On a graceful Shutdown the context is still live: send the
+			// change that was waiting out its quiet period.
+			if pending != nil && r.ctx.Err() == nil {
+				r.flushPending(pending)
+			}
 			return
 		case <-ticker.C:
+			if pending != nil && r.now().Sub(pendingSince) >= r.coalesce {
+				r.flushPending(pending)
+				pending = nil
+			}
+
+			token, tokenErr := r.clipboard.ChangeToken()
+			if tokenErr == nil && haveToken && token == lastToken {
+				continue
+			}
+
 			content, err := r.clipboard.Read()
 			if err != nil {
 				continue
 			}
+			if tokenErr == nil {
+				lastToken, haveToken = token, true
+			}
 
 			if !r.clipboard.HasChanged(content.Hash) {
 				continue
 			}
 
 			r.clipboard.SetLastHash(content.Hash)
+			if r.coalesce > 0 {
+				pending, pendingSince = content, r.now()
+				continue
+			}
+			r.publishLocal(content)
+		}
+	}
+}
+
+// flushPending publishes a change held back by coalescing, unless content
+// received from another machine has been written to the clipboard since.
+// That content is newer, and publishing the stale change would overwrite
+// it everywhere.
+func (r *Relay) flushPending(pending *clipboard.Content) {
+	if r.clipboard.HasChanged(pending.Hash) {
+		if r.verbose {
+			r.logger.Printf("Dropping held-back %s: superseded by received content", pending.Type)
+		}
+		return
+	}
+	r.publishLocal(pending)
+}
+
+// publishLocal publishes a change read from the local clipboard and, if any
+// clipboard accepted it, marks it as sent so it can be persisted. Content
+// that was filtered out, e.g. concealed or excluded, is never marked.
+func (r *Relay) publishLocal(content *clipboard.Content) {
+	if r.publish(content) > 0 {
+		r.clipboard.MarkSent(content.Hash)
+	}
+}
+
+// publishOnTrigger publishes the current clipboard each time the explicit-push
+// trigger fires. Unlike the poller it publishes even if the content has not
+// changed: pressing the hotkey is a deliberate request to send.
+func (r *Relay) publishOnTrigger() {
+	defer r.wg.Done()
 
-			// Publish to selected clipboards (all in spoke mode; filtered in hub mode).
-			for _, room := range r.rooms {
-				if !r.shouldPublishTo(room.name) {
-					continue
-				}
-				// Encrypt — mandatory, refuse to publish if no key.
-				if room.encKey == nil {
-					r.logger.Printf("ERROR: clipboard '%s' has no encryption key — refusing to publish", room.name)
-					continue
-				}
-
-				// Enforce Ably's 64 KB message limit early, before doing
-				// encryption work.  base64(nonce+ts+data+gcm) + JSON overhead
-				// means the usable plaintext limit is ~47 KB.
-				if len(content.Data) > maxPlaintextBytes {
-					r.logger.Printf("WARNING: clipboard payload too large for clipboard '%s' (%d bytes, limit %d) — dropping", room.name, len(content.Data), maxPlaintextBytes)
-					continue
-				}
-
-				// Prepend 8-byte big-endian Unix timestamp inside the
-				// AEAD envelope so receivers can reject replayed messages.
-				ts := make([]byte, 8)
-				binary.BigEndian.PutUint64(ts, uint64(time.Now().Unix()))
-				payload := append(ts, content.Data...)
-
-				// Room name as AAD binds ciphertext to this room.
-				ciphertext, err := encrypt(room.encKey, payload, []byte(room.name))
-				if err != nil {
-					r.logger.Printf("Failed to encrypt for clipboard '%s': %v", room.name, err)
-					continue
-				}
-
-				amsg := ablyMsg{
-					Type:   uint8(content.Type),
-					Data:   base64.StdEncoding.EncodeToString(ciphertext),
-					Sender: r.sender,
-				}
-				amsg.MAC = computeMAC(room.encKey, amsg)
-
-				msgJSON, err := json.Marshal(amsg)
-				if err != nil {
-					r.logger.Printf("Failed to marshal message for clipboard '%s': %v", room.name, err)
-					continue
-				}
-
-				// Final wire-size safety net: the serialised JSON must fit within
-				// Ably's hard limit.  Under normal circumstances the plaintext
-				// guard above prevents reaching here with an oversized payload;
-				// this catches any unexpected overhead (e.g. very long room names).
-				if len(msgJSON) > ablyMessageSizeLimit {
-					r.logger.Printf("WARNING: serialised message too large for clipboard '%s' (%d bytes, Ably limit %d) — dropping", room.name, len(msgJSON), ablyMessageSizeLimit)
-					continue
-				}
-
-				err = room.channel.Publish(r.ctx, "clipboard", string(msgJSON))
-				if err != nil {
-					r.logger.Printf("Failed to publish to clipboard %s: %v", room.name, err)
-				} else {
-					r.recordSync()
-				}
-				if err == nil && r.verbose {
-					typeStr := "text"
-					if content.Type == clipboard.TypeImage {
-						typeStr = "image"
-					}
-					r.logger.Printf("Published %s (%d bytes) to clipboard '%s' (encrypted)", typeStr, len(content.Data), room.name)
-				}
+	for {
+		select {
+		case <-r.stopChan:
+			return
+		case _, ok := <-r.trigger:
+			if !ok {
+				return
+			}
+			content, err := r.clipboard.Read()
+			if err != nil {
+				r.logger.Printf("Push: failed to read clipboard: %v", err)
+				continue
 			}
+			r.clipboard.SetLastHash(content.Hash)
+			r.publishLocal(content)
+		}
+	}
+}
+
+// PublishOnce reads the local clipboard and publishes it a single time to
+// every clipboard this relay sends to, without subscribing or polling. It
+// returns how many clipboards the broker acknowledged. Call Stop afterwards.
+func (r *Relay) PublishOnce() (int, error) {
+	content, err := r.clipboard.Read()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read clipboard: %w", err)
+	}
+	return r.PublishContent(content), nil
+}
+
+// PublishContent is PublishOnce for content the caller supplies, e.g. read
+// from stdin, instead of the local clipboard.
+func (r *Relay) PublishContent(content *clipboard.Content) int {
+	for _, room := range r.rooms {
+		if room.channel == nil {
+			room.channel = r.client.Channels.Get(room.name, r.channelOptions()...)
+		}
+	}
+	return r.publish(content)
+}
+
+// publish sends content to each eligible clipboard and returns how many
+// publishes the broker acknowledged.
+func (r *Relay) publish(content *clipboard.Content) int {
+	return r.publishTo(r.rooms, content, 0)
+}
+
+// publishTo is publish limited to rooms, with flags set in the wire type.
+func (r *Relay) publishTo(rooms []*roomSub, content *clipboard.Content, flags uint8) int {
+	if !r.syncsType(content.Type) {
+		if r.verbose {
+			r.logger.Printf("Not publishing %s (not in sync types)", content.Type)
+		}
+		return 0
+	}
+	if r.excluded(content) {
+		return 0
+	}
+	content = r.scaleImage(content)
+	wireType, data := r.wireFormat(content)
+	wireType |= flags
+
+	published := 0
+	// Publish to selected clipboards (all in spoke mode; filtered in hub mode).
+	for _, room := range rooms {
+		if !r.publishesTo(room) {
+			continue
+		}
+		if r.dryRun {
+			r.logWouldPublish(room, content)
+			continue
+		}
+		if r.send(room, wireType, data) {
+			published++
+			r.recordPublished(room, content)
+		}
+	}
+	return published
+}
+
+// scaleImage returns content with an image downscaled to the configured
+// bound. On failure it logs and returns content unchanged.
+func (r *Relay) scaleImage(content *clipboard.Content) *clipboard.Content {
+	if content.Type != clipboard.TypeImage || r.maxImageDim <= 0 {
+		return content
+	}
+	data, scaled, err := clipboard.DownscalePNG(content.Data, r.maxImageDim)
+	if err != nil {
+		r.logger.Printf("Failed to downscale image: %v — sending as copied", err)
+		return content
+	}
+	if !scaled {
+		return content
+	}
+	if r.verbose {
+		r.logger.Printf("Downscaled image from %d to %d bytes (max %dpx)", len(content.Data), len(data), r.maxImageDim)
+	}
+	scaledContent := *content
+	scaledContent.Data = data
+	return &scaledContent
+}
+
+// publishesTo reports whether local clipboard content may be sent to room.
+func (r *Relay) publishesTo(room *roomSub) bool {
+	return room.direction != ReceiveOnly && r.shouldPublishTo(room.name)
+}
+
+// wireFormat returns the type byte and payload for content, as JPEG and
+// compressed when enabled and worthwhile.
+func (r *Relay) wireFormat(content *clipboard.Content) (uint8, []byte) {
+	wireType, data := uint8(content.Type), content.Data
+	if j, ok := r.jpegPayload(content); ok {
+		wireType, data = typeJPEG, j
+	}
+	if r.compress {
+		if z, ok := compressPayload(data); ok {
+			wireType, data = wireType|typeCompressed, z
 		}
 	}
+	return wireType, data
+}
+
+func (r *Relay) logWouldPublish(room *roomSub, content *clipboard.Content) {
+	r.logger.Printf("Dry run: would publish %s (%d bytes, hash %.12s) to clipboard '%s'", content.Type, len(content.Data), content.Hash, room.name)
+}
+
+func (r *Relay) recordPublished(room *roomSub, content *clipboard.Content) {
+	r.recordSync()
+	room.stats.itemsSent.Add(1)
+	room.stats.bytesSent.Add(uint64(len(content.Data)))
+	if r.verbose {
+		r.logger.Printf("Published %s (%d bytes) to clipboard '%s' (encrypted)", content.Type, len(content.Data), room.name)
+	}
+}
+
+// send encrypts, authenticates and publishes one message to room. It logs
+// and returns false on failure.
+func (r *Relay) send(room *roomSub, wireType uint8, data []byte) bool {
+	// Encrypt — mandatory, refuse to publish if no key.
+	if room.encKey == nil {
+		r.logger.Printf("ERROR: clipboard '%s' has no encryption key — refusing to publish", room.name)
+		return false
+	}
+
+	// Enforce Ably's 64 KB message limit early, before doing
+	// encryption work.  base64(nonce+ts+data+gcm) + JSON overhead
+	// means the usable plaintext limit is ~47 KB.
+	if len(data) > maxPlaintextBytes {
+		r.logger.Printf("WARNING: clipboard payload too large for clipboard '%s' (%d bytes, limit %d) — dropping", room.name, len(data), maxPlaintextBytes)
+		return false
+	}
+
+	// Prepend 8-byte big-endian Unix timestamp inside the
+	// AEAD envelope so receivers can reject replayed messages.
+	ts := make([]byte, 8)
+	binary.BigEndian.PutUint64(ts, uint64(r.now().Unix()))
+	payload := append(ts, data...)
+
+	// Room name as AAD binds ciphertext to this room.
+	ciphertext, err := encryptWith(r.randReader(), room.encKey, payload, []byte(room.name))
+	if err != nil {
+		r.logger.Printf("Failed to encrypt for clipboard '%s': %v", room.name, err)
+		return false
+	}
+
+	amsg := ablyMsg{
+		Type:   wireType,
+		Data:   base64.StdEncoding.EncodeToString(ciphertext),
+		Sender: r.sender,
+	}
+	amsg.MAC = computeMAC(room.encKey, amsg)
+
+	msgJSON, err := json.Marshal(amsg)
+	if err != nil {
+		r.logger.Printf("Failed to marshal message for clipboard '%s': %v", room.name, err)
+		return false
+	}
+
+	// Final wire-size safety net: the serialised JSON must fit within
+	// Ably's hard limit.  Under normal circumstances the plaintext
+	// guard above prevents reaching here with an oversized payload;
+	// this catches any unexpected overhead (e.g. very long room names).
+	if len(msgJSON) > ablyMessageSizeLimit {
+		r.logger.Printf("WARNING: serialised message too large for clipboard '%s' (%d bytes, Ably limit %d) — dropping", room.name, len(msgJSON), ablyMessageSizeLimit)
+		return false
+	}
+
+	if err := room.channel.Publish(r.ctx, "clipboard", string(msgJSON)); err != nil {
+		r.logger.Printf("Failed to publish to clipboard %s: %v", room.name, err)
+		return false
+	}
+	return true
 }
 
-// plaintextHash returns the SHA-256 hex digest of data, matching the hash
-// scheme used by the clipboard package so SetLastHash stays consistent.
+// plaintextHash returns the content hash of data. It delegates to
+// clipboard.HashData so the hash a receiver records via Write is identical to
+// the hash the sender's poller computed on Read.
 func plaintextHash(data []byte) string {
-	h := sha256.Sum256(data)
-	return hex.EncodeToString(h[:])
+	return clipboard.HashData(data)
 }
 
 // computeMAC returns HMAC-SHA256(key, "t:d:s") as a hex string.
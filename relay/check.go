@@ -0,0 +1,34 @@
+package relay
+
+import "context"
+
+// CheckResult is the outcome of Check for one clipboard.
+type CheckResult struct {
+	Name        string
+	Fingerprint string // key fingerprint, to compare with other machines out of band
+	Err         error  // nil if the clipboard's channel attached
+}
+
+// channelAttacher is implemented by *ably.RealtimeChannel.
+type channelAttacher interface {
+	Attach(ctx context.Context) error
+}
+
+// Check connects to Ably and attaches to each clipboard's channel, without
+// subscribing, polling or touching the local clipboard, and reports the
+// result along with the clipboard's key fingerprint. Two machines whose
+// fingerprints for a clipboard match will be able to read each other's
+// copies. Call Stop afterwards.
+func (r *Relay) Check(ctx context.Context) []CheckResult {
+	results := make([]CheckResult, len(r.rooms))
+	for i, room := range r.rooms {
+		if room.channel == nil {
+			room.channel = r.client.Channels.Get(room.name)
+		}
+		results[i] = CheckResult{Name: room.name, Fingerprint: keyFingerprint(room.encKey)}
+		if a, ok := room.channel.(channelAttacher); ok {
+			results[i].Err = a.Attach(ctx)
+		}
+	}
+	return results
+}
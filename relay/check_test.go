@@ -0,0 +1,40 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// attachChannel is a fakeChannel whose Attach returns err.
+type attachChannel struct {
+	*fakeChannel
+	err error
+}
+
+func (a *attachChannel) Attach(ctx context.Context) error { return a.err }
+
+func TestCheck(t *testing.T) {
+	good := testRoom("hunter2hunter2", "good")
+	good.channel = &attachChannel{fakeChannel: newFakeChannel()}
+	bad := testRoom("hunter2hunter2", "bad")
+	denied := errors.New("channel denied")
+	bad.channel = &attachChannel{fakeChannel: newFakeChannel(), err: denied}
+
+	r := buildRelay(t, good, &fakeClipboard{}, "self", false)
+	r.rooms = append(r.rooms, bad)
+
+	results := r.Check(context.Background())
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if got := results[0]; got.Name != "good" || got.Err != nil || got.Fingerprint != keyFingerprint(good.encKey) {
+		t.Errorf("good clipboard: %+v", got)
+	}
+	if got := results[1]; got.Name != "bad" || !errors.Is(got.Err, denied) {
+		t.Errorf("bad clipboard: %+v", got)
+	}
+	if results[0].Fingerprint == results[1].Fingerprint {
+		t.Error("clipboards with different names should have different key fingerprints")
+	}
+}
@@ -0,0 +1,57 @@
+package relay
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// typeCompressed is set in ablyMsg.Type when the plaintext was gzipped
+// before encryption. The type byte is covered by the MAC, so the flag cannot
+// be flipped in transit. Versions without compression support do not check
+// it and write the gzip bytes to the clipboard as text.
+const typeCompressed uint8 = 0x80
+
+// maxDecompressedBytes caps an inflated payload so a small message cannot
+// expand into an arbitrarily large clipboard write.
+const maxDecompressedBytes = 16 * 1024 * 1024
+
+// SetCompression makes the relay gzip payloads before encrypting them when
+// that makes them smaller, which lets larger text fit under Ably's message
+// limit. Receivers always accept compressed messages, but there is no
+// handshake to detect peers that cannot, so only enable this once every
+// machine on the clipboard is up to date; see typeCompressed. Must be called
+// before Start.
+func (r *Relay) SetCompression(enabled bool) {
+	r.compress = enabled
+}
+
+// compressPayload returns gzip(data) and true, or data and false if
+// compression does not save space (e.g. PNG, which is already deflated).
+func compressPayload(data []byte) ([]byte, bool) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data) // writes to a bytes.Buffer cannot fail
+	zw.Close()
+	if buf.Len() >= len(data) {
+		return data, false
+	}
+	return buf.Bytes(), true
+}
+
+func decompressPayload(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	out, err := io.ReadAll(io.LimitReader(zr, maxDecompressedBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > maxDecompressedBytes {
+		return nil, fmt.Errorf("decompressed payload exceeds %d bytes", maxDecompressedBytes)
+	}
+	return out, nil
+}
@@ -0,0 +1,80 @@
+package relay
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ably/ably-go/ably"
+	"github.com/mindmorass/paperclip/clipboard"
+)
+
+func TestCompression_LargeTextRoundTrips(t *testing.T) {
+	room := testRoom("hunter2hunter2", "testroom")
+	ch := newFakeChannel()
+	room.channel = ch
+	sender := startable(buildRelay(t, room, &fakeClipboard{}, "sender", false))
+	sender.SetCompression(true)
+
+	// Well over maxPlaintextBytes, but highly compressible.
+	text := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog\n", 4000))
+	sender.publish(&clipboard.Content{Type: clipboard.TypeText, Data: text})
+
+	published := ch.Published()
+	if len(published) != 1 {
+		t.Fatalf("expected 1 publish, got %d", len(published))
+	}
+	var msg ablyMsg
+	if err := json.Unmarshal([]byte(published[0]), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != uint8(clipboard.TypeText)|typeCompressed {
+		t.Errorf("wire type = %#x, want compressed text", msg.Type)
+	}
+
+	cb := &fakeClipboard{}
+	receiver := startable(buildRelay(t, room, cb, "receiver", false))
+	receiver.handleMessage(room, &ably.Message{Data: published[0]})
+	got := cb.LastWrite()
+	if got == nil {
+		t.Fatal("compressed message was not written")
+	}
+	if got.Type != clipboard.TypeText || !bytes.Equal(got.Data, text) {
+		t.Errorf("received type %v, %d bytes; want text, %d bytes", got.Type, len(got.Data), len(text))
+	}
+}
+
+func TestCompression_IncompressibleSentRaw(t *testing.T) {
+	room := testRoom("hunter2hunter2", "testroom")
+	ch := newFakeChannel()
+	room.channel = ch
+	r := startable(buildRelay(t, room, &fakeClipboard{}, "sender", false))
+	r.SetCompression(true)
+
+	data := make([]byte, 4096)
+	rand.Read(data)
+	r.publish(&clipboard.Content{Type: clipboard.TypeImage, Data: data})
+
+	var msg ablyMsg
+	if err := json.Unmarshal([]byte(ch.Published()[0]), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != uint8(clipboard.TypeImage) {
+		t.Errorf("wire type = %#x, want uncompressed image", msg.Type)
+	}
+	if got := decodePublished(t, room, ch.Published()[0]); !bytes.Equal(got, data) {
+		t.Error("raw payload altered")
+	}
+}
+
+func TestDecompressPayload_RejectsBomb(t *testing.T) {
+	bomb, ok := compressPayload(make([]byte, maxDecompressedBytes+1))
+	if !ok {
+		t.Fatal("zeros should compress")
+	}
+	if _, err := decompressPayload(bomb); err == nil {
+		t.Error("expected error for oversized payload, got nil")
+	}
+}
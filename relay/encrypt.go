@@ -5,6 +5,7 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
 	"io"
 
@@ -20,11 +21,25 @@ func deriveKey(passphrase, room string) []byte {
 	return argon2.IDKey([]byte(passphrase), salt[:], 2, 64*1024, 4, 32)
 }
 
+// keyFingerprint returns an SSH-style "SHA256:<base64>" fingerprint of a
+// derived clipboard key, so users can confirm out of band that two machines
+// share a passphrase. The key is hashed under a distinct prefix so the
+// fingerprint is never a value used elsewhere in the protocol.
+func keyFingerprint(key []byte) string {
+	sum := sha256.Sum256(append([]byte("paperclip-fingerprint:"), key...))
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
 // encrypt encrypts plaintext using AES-256-GCM with the given key.
 // aad is included as additional authenticated data (e.g. room name) to bind
 // ciphertexts to a specific context and prevent cross-room replay.
 // Returns nonce + ciphertext.
 func encrypt(key, plaintext, aad []byte) ([]byte, error) {
+	return encryptWith(rand.Reader, key, plaintext, aad)
+}
+
+// encryptWith is encrypt with the nonce drawn from rnd.
+func encryptWith(rnd io.Reader, key, plaintext, aad []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cipher: %w", err)
@@ -36,7 +51,7 @@ func encrypt(key, plaintext, aad []byte) ([]byte, error) {
 	}
 
 	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+	if _, err := io.ReadFull(rnd, nonce); err != nil {
 		return nil, fmt.Errorf("failed to generate nonce: %w", err)
 	}
 
@@ -2,6 +2,7 @@ package relay
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 )
 
@@ -194,3 +195,25 @@ func TestDeriveKeyPassphraseIsolation(t *testing.T) {
 		t.Error("different passphrases for the same room produced the same key")
 	}
 }
+
+func TestKeyFingerprintFormat(t *testing.T) {
+	fp := keyFingerprint(testKey(t))
+	// "SHA256:" followed by 43 chars of unpadded base64 of a 32-byte digest.
+	if !strings.HasPrefix(fp, "SHA256:") || len(fp) != len("SHA256:")+43 {
+		t.Fatalf("keyFingerprint = %q, want SHA256:<43 base64 chars>", fp)
+	}
+	if strings.Contains(fp, "=") {
+		t.Errorf("keyFingerprint = %q, want no padding", fp)
+	}
+}
+
+func TestKeyFingerprintDistinguishesPassphrases(t *testing.T) {
+	a := keyFingerprint(deriveKey("one", "room"))
+	b := keyFingerprint(deriveKey("two", "room"))
+	if a == b {
+		t.Error("different passphrases produced the same fingerprint")
+	}
+	if a != keyFingerprint(deriveKey("one", "room")) {
+		t.Error("fingerprint is not deterministic")
+	}
+}
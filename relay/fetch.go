@@ -0,0 +1,147 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ably/ably-go/ably"
+	"github.com/mindmorass/paperclip/clipboard"
+)
+
+// typeFetchRequest asks running paperclips on a clipboard to publish their
+// current content. It carries no payload. Versions without fetch support
+// write any unrecognised type to the clipboard as text, so an older machine
+// would replace its clipboard with an empty string; see SetFetch.
+const typeFetchRequest uint8 = 0x40
+
+// typeFetchReply is set in ablyMsg.Type on an answer to a fetch request.
+// Only Fetch accepts such messages; the relay never writes them to the
+// local clipboard, so answering does not overwrite other machines.
+const typeFetchReply uint8 = 0x20
+
+// ErrFetchDisabled is returned by Fetch unless SetFetch(true) was called.
+var ErrFetchDisabled = errors.New("fetch is disabled; enable it once every machine on the clipboard supports it")
+
+// fetchRequestMaxAge bounds how old a fetch request may be and still be
+// answered, so one replayed on connect (sync-on-connect rewind) does not
+// make a machine push its clipboard long after anyone asked.
+const fetchRequestMaxAge = 30 * time.Second
+
+// ErrNoAnswer is returned by Fetch when no running paperclip answered.
+var ErrNoAnswer = errors.New("no paperclip answered the fetch request")
+
+// SetFetch enables Fetch and answering other machines' fetch requests.
+// Every machine on the clipboard must run a version that supports fetch
+// before it is enabled anywhere: older versions paste both the request and
+// the answer as text. Must be called before Start.
+func (r *Relay) SetFetch(enabled bool) {
+	r.fetch = enabled
+}
+
+// Fetch asks the paperclips running on the named clipboard for their current
+// content and returns the first answer, without touching the local
+// clipboard. Machines that are receive-only, publish only on a hotkey, or
+// have fetch disabled do not answer; if nobody does, Fetch returns
+// ErrNoAnswer once ctx is done.
+//
+// The answer is marked as a reply, so the other machines on the clipboard
+// ignore it.
+func (r *Relay) Fetch(ctx context.Context, name string) (*clipboard.Content, error) {
+	if !r.fetch {
+		return nil, ErrFetchDisabled
+	}
+	room, err := r.roomNamed(name)
+	if err != nil {
+		return nil, err
+	}
+	answers, unsub, err := r.awaitContent(ctx, room, true)
+	if err != nil {
+		return nil, err
+	}
+	defer unsub()
+
+	if !r.send(room, typeFetchRequest, nil) {
+		return nil, fmt.Errorf("failed to send fetch request to clipboard %s", name)
+	}
+
+	select {
+	case content := <-answers:
+		return content, nil
+	case <-ctx.Done():
+		return nil, ErrNoAnswer
+	}
+}
+
+// Receive waits for the next item published to the named clipboard and
+// returns it without touching the local clipboard. It returns ctx's error if
+// ctx is done first.
+func (r *Relay) Receive(ctx context.Context, name string) (*clipboard.Content, error) {
+	room, err := r.roomNamed(name)
+	if err != nil {
+		return nil, err
+	}
+	items, unsub, err := r.awaitContent(ctx, room, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unsub()
+
+	select {
+	case content := <-items:
+		return content, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (r *Relay) roomNamed(name string) (*roomSub, error) {
+	for _, rm := range r.rooms {
+		if rm.name == name {
+			return rm, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown clipboard %q", name)
+}
+
+// awaitContent subscribes to room and delivers the first item received on
+// it: a fetch answer if replies is set, otherwise an ordinary publish. Call
+// unsub when done.
+func (r *Relay) awaitContent(ctx context.Context, room *roomSub, replies bool) (<-chan *clipboard.Content, func(), error) {
+	if room.channel == nil {
+		// No rewind: a replayed message is not an answer to this request.
+		room.channel = r.client.Channels.Get(room.name)
+	}
+
+	items := make(chan *clipboard.Content, 1)
+	unsub, err := room.channel.SubscribeAll(ctx, func(msg *ably.Message) {
+		in, ok := r.decodeMessage(room, msg)
+		if !ok || in.reply != replies || !clipboard.ContentType(in.typ).Known() {
+			return
+		}
+		select {
+		case items <- &clipboard.Content{Type: clipboard.ContentType(in.typ), Data: in.data, Hash: plaintextHash(in.data)}:
+		default:
+		}
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to subscribe to clipboard %s: %w", room.name, err)
+	}
+	return items, unsub, nil
+}
+
+// answerFetch replies to a fetch request by publishing the local clipboard,
+// marked as a reply, to the clipboard it came from. Nothing is sent with
+// fetch disabled, in explicit-push mode, or to a receive-only clipboard.
+func (r *Relay) answerFetch(room *roomSub, req inbound) {
+	if !r.fetch || r.trigger != nil || r.now().Sub(req.sentAt) > fetchRequestMaxAge {
+		return
+	}
+	content, err := r.clipboard.Read()
+	if err != nil {
+		r.logger.Printf("Failed to read clipboard for fetch request: %v", err)
+		return
+	}
+	r.publishTo([]*roomSub{room}, content, typeFetchReply)
+}
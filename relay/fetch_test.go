@@ -0,0 +1,198 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/ably/ably-go/ably"
+	"github.com/mindmorass/paperclip/clipboard"
+)
+
+// fetchPair returns a fetching relay and a running daemon on a shared
+// broker, both with fetch enabled; the daemon's clipboard holds "remote
+// copy".
+func fetchPair(t *testing.T) (fetcher, daemon *Relay, fetcherCB *fakeClipboard) {
+	t.Helper()
+	broker := &fakeBroker{}
+	shared := testRoom("hunter2hunter2", "testroom")
+
+	fetcherCB = &fakeClipboard{}
+	fetcher = startable(buildRelay(t, &roomSub{name: shared.name, encKey: shared.encKey, channel: &brokerChannel{b: broker}}, fetcherCB, "fetcher", false))
+	fetcher.SetFetch(true)
+
+	daemonCB := &fakeClipboard{content: &clipboard.Content{Type: clipboard.TypeText, Data: []byte("remote copy"), Hash: "h"}}
+	daemon = joinRunning(t, fetcher, daemonCB, "daemon")
+	daemon.SetFetch(true)
+	return fetcher, daemon, fetcherCB
+}
+
+// joinRunning starts a relay on the same broker and clipboard as peer,
+// handling messages as a running daemon does.
+func joinRunning(t *testing.T, peer *Relay, cb *fakeClipboard, sender string) *Relay {
+	t.Helper()
+	shared := peer.rooms[0]
+	room := &roomSub{name: shared.name, encKey: shared.encKey, channel: &brokerChannel{b: shared.channel.(*brokerChannel).b}}
+	r := startable(buildRelay(t, room, cb, sender, false))
+	if _, err := room.channel.SubscribeAll(r.ctx, func(msg *ably.Message) { r.handleMessage(room, msg) }); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// fetchExpectingNoAnswer fails the test unless Fetch times out unanswered.
+func fetchExpectingNoAnswer(t *testing.T, fetcher *Relay) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := fetcher.Fetch(ctx, "testroom"); !errors.Is(err, ErrNoAnswer) {
+		t.Errorf("Fetch error = %v, want ErrNoAnswer", err)
+	}
+}
+
+func TestFetch_ReturnsRemoteClipboard(t *testing.T) {
+	fetcher, _, fetcherCB := fetchPair(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	content, err := fetcher.Fetch(ctx, "testroom")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if content.Type != clipboard.TypeText || string(content.Data) != "remote copy" {
+		t.Errorf("Fetch = %v %q, want text \"remote copy\"", content.Type, content.Data)
+	}
+	if fetcherCB.WriteCount() != 0 {
+		t.Error("Fetch must not write the local clipboard")
+	}
+}
+
+func TestFetch_AnswerNotWrittenByOtherMachines(t *testing.T) {
+	fetcher, _, _ := fetchPair(t)
+	bystanderCB := &fakeClipboard{}
+	bystander := joinRunning(t, fetcher, bystanderCB, "bystander")
+	bystander.SetFetch(true)
+	bystander.SetDirection("testroom", ReceiveOnly) // so only the daemon answers
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := fetcher.Fetch(ctx, "testroom"); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if n := bystanderCB.WriteCount(); n != 0 {
+		t.Errorf("another machine wrote the fetch answer to its clipboard %d times", n)
+	}
+}
+
+func TestFetch_Disabled(t *testing.T) {
+	fetcher, _, _ := fetchPair(t)
+	fetcher.SetFetch(false)
+	if _, err := fetcher.Fetch(context.Background(), "testroom"); !errors.Is(err, ErrFetchDisabled) {
+		t.Errorf("Fetch error = %v, want ErrFetchDisabled", err)
+	}
+}
+
+func TestFetch_NotAnsweredWithFetchDisabled(t *testing.T) {
+	fetcher, daemon, _ := fetchPair(t)
+	daemon.SetFetch(false)
+	fetchExpectingNoAnswer(t, fetcher)
+}
+
+func TestFetch_ExplicitPushDoesNotAnswer(t *testing.T) {
+	fetcher, daemon, _ := fetchPair(t)
+	daemon.PublishOn(make(chan struct{}))
+	fetchExpectingNoAnswer(t, fetcher)
+}
+
+func TestFetch_ReceiveOnlyDoesNotAnswer(t *testing.T) {
+	fetcher, daemon, _ := fetchPair(t)
+	daemon.SetDirection("testroom", ReceiveOnly)
+	fetchExpectingNoAnswer(t, fetcher)
+}
+
+func TestFetch_StaleRequestIgnored(t *testing.T) {
+	fetcher, daemon, _ := fetchPair(t)
+	clock := &fakeClock{t: time.Now()}
+	daemon.nowFunc = clock.Now
+	clock.Advance(fetchRequestMaxAge + time.Second) // still inside the replay window
+	fetchExpectingNoAnswer(t, fetcher)
+}
+
+func TestFetch_AnswerDownscalesImages(t *testing.T) {
+	fetcher, textDaemon, _ := fetchPair(t)
+	textDaemon.SetFetch(false) // leave the image daemon as the only one to answer
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 80, 40))); err != nil {
+		t.Fatal(err)
+	}
+	daemon := joinRunning(t, fetcher, &fakeClipboard{content: &clipboard.Content{Type: clipboard.TypeImage, Data: buf.Bytes(), Hash: "img"}}, "image-daemon")
+	daemon.SetFetch(true)
+	daemon.SetMaxImageDimension(20)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	content, err := fetcher.Fetch(ctx, "testroom")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(content.Data))
+	if err != nil {
+		t.Fatalf("answer is not a PNG: %v", err)
+	}
+	if cfg.Width != 20 || cfg.Height != 10 {
+		t.Errorf("answered with a %dx%d image, want 20x10", cfg.Width, cfg.Height)
+	}
+}
+
+func TestFetch_UnknownClipboard(t *testing.T) {
+	fetcher, _, _ := fetchPair(t)
+	if _, err := fetcher.Fetch(context.Background(), "nope"); err == nil {
+		t.Error("expected error for unknown clipboard, got nil")
+	}
+}
+
+func TestReceive_ReturnsNextPublish(t *testing.T) {
+	receiver, sender, receiverCB := fetchPair(t)
+
+	// Keep publishing until Receive returns, since it subscribes only once
+	// called.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			sender.PublishContent(&clipboard.Content{Type: clipboard.TypeText, Data: []byte("piped in"), Hash: "p"})
+			select {
+			case <-done:
+				return
+			case <-time.After(5 * time.Millisecond):
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	content, err := receiver.Receive(ctx, "testroom")
+	if err != nil {
+		t.Fatalf("Receive: %v", err)
+	}
+	if content.Type != clipboard.TypeText || string(content.Data) != "piped in" {
+		t.Errorf("Receive = %v %q, want text \"piped in\"", content.Type, content.Data)
+	}
+	if receiverCB.WriteCount() != 0 {
+		t.Error("Receive must not write the local clipboard")
+	}
+}
+
+func TestReceive_ContextDone(t *testing.T) {
+	receiver, _, _ := fetchPair(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := receiver.Receive(ctx, "testroom"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Receive error = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,31 @@
+package relay
+
+import "github.com/mindmorass/paperclip/clipboard"
+
+// typeJPEG is a wire-only content type: an image the sender re-encoded as
+// JPEG to fit under the message limit. Receivers convert it back to PNG
+// before writing the clipboard. Older versions do not know the type and
+// write the JPEG bytes to the clipboard as text.
+const typeJPEG uint8 = 0x05
+
+// SetJPEGQuality makes the relay send photographic images that are too big
+// for one message as JPEG at quality (1-100) instead of PNG. Screenshots of
+// windows and text, images with transparency, and anything that already
+// fits stay PNG. Zero disables it. Only enable this once every receiver is
+// up to date; see typeJPEG. Must be called before Start.
+func (r *Relay) SetJPEGQuality(quality int) {
+	r.jpegQuality = quality
+}
+
+// jpegPayload returns the JPEG encoding of an image payload and true if it
+// should be sent that way.
+func (r *Relay) jpegPayload(content *clipboard.Content) ([]byte, bool) {
+	if content.Type != clipboard.TypeImage || r.jpegQuality <= 0 || len(content.Data) <= maxPlaintextBytes {
+		return nil, false
+	}
+	j, ok := clipboard.PhotoToJPEG(content.Data, r.jpegQuality)
+	if ok && r.verbose {
+		r.logger.Printf("Sending image as JPEG (%d bytes, PNG was %d)", len(j), len(content.Data))
+	}
+	return j, ok
+}
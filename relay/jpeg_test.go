@@ -0,0 +1,100 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"testing"
+
+	"github.com/ably/ably-go/ably"
+	"github.com/mindmorass/paperclip/clipboard"
+)
+
+// photoPNG returns a noisy gradient that is too big to send as PNG but
+// small as JPEG.
+func photoPNG(t *testing.T) []byte {
+	t.Helper()
+	const n = 192
+	img := image.NewRGBA(image.Rect(0, 0, n, n))
+	rnd := rand.New(rand.NewSource(1))
+	jitter := func(v int) uint8 { return uint8(max(0, min(255, v+rnd.Intn(9)-4))) }
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			img.SetRGBA(x, y, color.RGBA{jitter(x * 255 / n), jitter(y * 255 / n), jitter((x + y) * 255 / (2 * n)), 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() <= maxPlaintextBytes {
+		t.Fatalf("test image is only %d bytes; it must exceed the message limit", buf.Len())
+	}
+	return buf.Bytes()
+}
+
+func TestPublish_LargePhotoSentAsJPEG(t *testing.T) {
+	room := testRoom("hunter2hunter2", "testroom")
+	ch := newFakeChannel()
+	room.channel = ch
+	r := startable(buildRelay(t, room, &fakeClipboard{}, "self", false))
+	photo := &clipboard.Content{Type: clipboard.TypeImage, Data: photoPNG(t)}
+
+	// Off by default: the PNG is too large and is dropped.
+	r.publish(photo)
+	if n := len(ch.Published()); n != 0 {
+		t.Fatalf("oversized PNG was published (%d messages)", n)
+	}
+
+	r.SetJPEGQuality(80)
+	r.publish(photo)
+	if n := len(ch.Published()); n != 1 {
+		t.Fatalf("expected 1 publish with JPEG enabled, got %d", n)
+	}
+	var msg ablyMsg
+	if err := json.Unmarshal([]byte(ch.Published()[0]), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != typeJPEG {
+		t.Errorf("wire type = %#x, want typeJPEG", msg.Type)
+	}
+	if _, err := jpeg.DecodeConfig(bytes.NewReader(decodePublished(t, room, ch.Published()[0]))); err != nil {
+		t.Errorf("payload is not a JPEG: %v", err)
+	}
+}
+
+func TestHandleMessage_JPEGWrittenAsPNG(t *testing.T) {
+	room := testRoom("hunter2hunter2", "testroom")
+	cb := &fakeClipboard{}
+	r := buildRelay(t, room, cb, "self", false)
+
+	var j bytes.Buffer
+	if err := jpeg.Encode(&j, image.NewRGBA(image.Rect(0, 0, 8, 4)), nil); err != nil {
+		t.Fatal(err)
+	}
+	r.handleMessage(room, &ably.Message{Data: makeAblyMsg(t, room, "other", j.Bytes(), typeJPEG)})
+
+	if cb.WriteCount() != 1 {
+		t.Fatalf("expected 1 clipboard write, got %d", cb.WriteCount())
+	}
+	got := cb.LastWrite()
+	if got.Type != clipboard.TypeImage {
+		t.Errorf("written as %v, want image", got.Type)
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(got.Data))
+	if err != nil {
+		t.Fatalf("written data is not a PNG: %v", err)
+	}
+	if cfg.Width != 8 || cfg.Height != 4 {
+		t.Errorf("written %dx%d, want 8x4", cfg.Width, cfg.Height)
+	}
+
+	r.handleMessage(room, &ably.Message{Data: makeAblyMsg(t, room, "other", []byte("not a jpeg"), typeJPEG)})
+	if cb.WriteCount() != 1 {
+		t.Error("invalid JPEG was written to the clipboard")
+	}
+}
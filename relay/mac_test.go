@@ -2,6 +2,9 @@ package relay
 
 import (
 	"testing"
+
+	"github.com/ably/ably-go/ably"
+	"github.com/mindmorass/paperclip/clipboard"
 )
 
 func TestComputeMACDeterministic(t *testing.T) {
@@ -117,3 +120,23 @@ func TestPlaintextHashDifferentInputs(t *testing.T) {
 		t.Error("different inputs produced the same plaintextHash")
 	}
 }
+
+func TestPlaintextHashMatchesSender(t *testing.T) {
+	// The sender's poller hashes via clipboard.HashData on Read; the receiver
+	// must record the identical hash so both nodes agree on content identity.
+	data := []byte("same payload on both nodes")
+	sent := &clipboard.Content{Type: clipboard.TypeText, Data: data, Hash: clipboard.HashData(data)}
+
+	room := testRoom("hunter2hunter2", "testroom")
+	cb := &fakeClipboard{}
+	r := buildRelay(t, room, cb, "receiver", false)
+	r.handleMessage(room, &ably.Message{Data: makeAblyMsg(t, room, "sender", sent.Data, uint8(sent.Type))})
+
+	got := cb.LastWrite()
+	if got == nil {
+		t.Fatal("expected a clipboard write")
+	}
+	if got.Hash != sent.Hash {
+		t.Errorf("receiver hash %q differs from sender hash %q", got.Hash, sent.Hash)
+	}
+}
@@ -0,0 +1,52 @@
+package relay
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// metric is one counter or gauge family in the /metrics exposition.
+type metric struct {
+	name, typ, help string
+	value           func(ClipboardStatus) uint64
+}
+
+var roomMetrics = []metric{
+	{"paperclip_items_sent_total", "counter", "Clipboard items published.", func(s ClipboardStatus) uint64 { return s.ItemsSent }},
+	{"paperclip_items_received_total", "counter", "Clipboard items received and written.", func(s ClipboardStatus) uint64 { return s.ItemsReceived }},
+	{"paperclip_bytes_sent_total", "counter", "Plaintext bytes published.", func(s ClipboardStatus) uint64 { return s.BytesSent }},
+	{"paperclip_bytes_received_total", "counter", "Plaintext bytes received and written.", func(s ClipboardStatus) uint64 { return s.BytesReceived }},
+	{"paperclip_dedup_dropped_total", "counter", "Received items dropped because the clipboard already held them.", func(s ClipboardStatus) uint64 { return s.DedupDropped }},
+	{"paperclip_auth_failures_total", "counter", "Received messages that failed HMAC verification or decryption.", func(s ClipboardStatus) uint64 { return s.AuthFailures }},
+}
+
+// writeMetrics writes the relay's counters in the Prometheus text
+// exposition format, one series per clipboard.
+func writeMetrics(w io.Writer, report StatusReport) {
+	connected := 0
+	if report.Connected {
+		connected = 1
+	}
+	fmt.Fprintf(w, "# HELP paperclip_connected Whether the relay is connected to Ably.\n")
+	fmt.Fprintf(w, "# TYPE paperclip_connected gauge\n")
+	fmt.Fprintf(w, "paperclip_connected %d\n", connected)
+
+	for _, m := range roomMetrics {
+		fmt.Fprintf(w, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.typ)
+		for _, cs := range report.Clipboards {
+			fmt.Fprintf(w, "%s{clipboard=\"%s\"} %d\n", m.name, escapeLabel(cs.Name), m.value(cs))
+		}
+	}
+
+	if report.LastSyncAt != nil {
+		fmt.Fprintf(w, "# HELP paperclip_last_sync_timestamp_seconds Unix time of the last item sent or received.\n")
+		fmt.Fprintf(w, "# TYPE paperclip_last_sync_timestamp_seconds gauge\n")
+		fmt.Fprintf(w, "paperclip_last_sync_timestamp_seconds %d\n", report.LastSyncAt.Unix())
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string { return labelEscaper.Replace(v) }
@@ -0,0 +1,52 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// errNotTerminal is returned when a passphrase prompt is asked for but
+// stdin is not an interactive terminal.
+var errNotTerminal = errors.New("cannot prompt for passphrase: stdin is not a terminal")
+
+// promptPassphrase asks for the passphrase on out and reads one line from
+// the terminal in with echo turned off.
+func promptPassphrase(in *os.File, out io.Writer) (string, error) {
+	restore, err := disableEcho(int(in.Fd()))
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	fmt.Fprint(out, "Passphrase: ")
+	line, err := readLine(in)
+	fmt.Fprintln(out) // the Enter key was not echoed either
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return line, nil
+}
+
+// readLine reads up to and including the next newline a byte at a time, so
+// nothing after the passphrase is consumed from in.
+func readLine(in io.Reader) (string, error) {
+	var line []byte
+	var b [1]byte
+	for {
+		n, err := in.Read(b[:])
+		if n == 1 {
+			line = append(line, b[0])
+			if b[0] == '\n' {
+				return string(line), nil
+			}
+		}
+		if err == io.EOF && len(line) > 0 {
+			return string(line), nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !darwin && !windows && !linux
+
+package relay
+
+import "errors"
+
+func disableEcho(int) (func(), error) {
+	return nil, errors.New("passphrase prompt is not supported on this platform")
+}
@@ -0,0 +1,18 @@
+package relay
+
+import "golang.org/x/sys/windows"
+
+// disableEcho turns off console echo on fd and returns a function that
+// restores the previous mode.
+func disableEcho(fd int) (restore func(), err error) {
+	h := windows.Handle(fd)
+	var old uint32
+	if err := windows.GetConsoleMode(h, &old); err != nil {
+		return nil, errNotTerminal
+	}
+	mode := old&^windows.ENABLE_ECHO_INPUT | windows.ENABLE_LINE_INPUT | windows.ENABLE_PROCESSED_INPUT
+	if err := windows.SetConsoleMode(h, mode); err != nil {
+		return nil, err
+	}
+	return func() { windows.SetConsoleMode(h, old) }, nil
+}
@@ -0,0 +1,55 @@
+package relay
+
+import (
+	"sync"
+	"time"
+)
+
+// Defaults for the inbound write limit: generous enough that nobody copying
+// by hand reaches them, tight enough that a flood of messages cannot thrash
+// the OS clipboard.
+const (
+	defaultInboundRate  = 5  // clipboard writes per second, sustained
+	defaultInboundBurst = 10 // writes allowed back to back
+)
+
+// rateLimiter is a token bucket; safe for concurrent use. The zero value
+// allows everything.
+type rateLimiter struct {
+	mu       sync.Mutex
+	rate     float64 // tokens per second; 0 = unlimited
+	burst    float64
+	tokens   float64
+	last     time.Time
+	dropping bool // the previous call was refused
+}
+
+func (l *rateLimiter) set(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate, l.burst = rate, float64(max(burst, 1))
+	l.tokens, l.last = l.burst, time.Time{}
+}
+
+// allow takes a token at time now. It reports whether the event may
+// proceed and, when refused, whether this is the first refusal since the
+// last allowed event, so callers can warn once per flood.
+func (l *rateLimiter) allow(now time.Time) (ok, firstDrop bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return true, false
+	}
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	if l.tokens < 1 {
+		first := !l.dropping
+		l.dropping = true
+		return false, first
+	}
+	l.tokens--
+	l.dropping = false
+	return true, false
+}
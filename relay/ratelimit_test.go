@@ -0,0 +1,67 @@
+package relay
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ably/ably-go/ably"
+	"github.com/mindmorass/paperclip/clipboard"
+)
+
+func TestRateLimiter_BurstThenRefill(t *testing.T) {
+	var l rateLimiter
+	l.set(2, 3)
+	now := time.Unix(1_700_000_000, 0)
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.allow(now); !ok {
+			t.Fatalf("event %d within burst refused", i)
+		}
+	}
+	ok, first := l.allow(now)
+	if ok || !first {
+		t.Errorf("event past burst: ok=%v first=%v, want refused first drop", ok, first)
+	}
+	if _, first := l.allow(now); first {
+		t.Error("second refusal reported as first drop")
+	}
+
+	// 2/s refills one token in half a second.
+	if ok, _ := l.allow(now.Add(500 * time.Millisecond)); !ok {
+		t.Error("event after refill refused")
+	}
+}
+
+func TestRateLimiter_ZeroValueUnlimited(t *testing.T) {
+	var l rateLimiter
+	for i := 0; i < 1000; i++ {
+		if ok, _ := l.allow(time.Time{}); !ok {
+			t.Fatal("zero-value limiter refused an event")
+		}
+	}
+}
+
+func TestHandleMessage_InboundBurstThrottled(t *testing.T) {
+	room := testRoom("hunter2hunter2", "testroom")
+	cb := &fakeClipboard{}
+	r := buildRelay(t, room, cb, "self", false)
+	clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+	r.nowFunc = clock.Now
+	r.SetInboundRateLimit(1)
+
+	for i := 0; i < 20; i++ {
+		data := makeAblyMsgAt(t, room, "flooder", []byte(fmt.Sprintf("spam %d", i)), uint8(clipboard.TypeText), clock.Now().Unix())
+		r.handleMessage(room, &ably.Message{Data: data})
+	}
+	if got := cb.WriteCount(); got != defaultInboundBurst {
+		t.Errorf("burst of 20: %d writes, want %d", got, defaultInboundBurst)
+	}
+
+	clock.Advance(time.Second)
+	data := makeAblyMsgAt(t, room, "flooder", []byte("after pause"), uint8(clipboard.TypeText), clock.Now().Unix())
+	r.handleMessage(room, &ably.Message{Data: data})
+	if got := cb.WriteCount(); got != defaultInboundBurst+1 {
+		t.Errorf("after a second: %d writes, want %d", got, defaultInboundBurst+1)
+	}
+}
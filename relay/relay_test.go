@@ -1,12 +1,18 @@
 package relay
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
 	"log"
 	"os"
+	"path/filepath"
+	"regexp"
 	"sync"
 	"testing"
 	"time"
@@ -21,11 +27,41 @@ type fakeClipboard struct {
 	content  *clipboard.Content
 	lastHash string
 	writes   []*clipboard.Content
+	reads    int
+	token    *uint64 // nil = no change token, as on unsupported platforms
+	sent     []string
+	store    *clipboard.Clipboard // if set, SetLastHash and MarkSent are passed on to it
+}
+
+func (f *fakeClipboard) ChangeToken() (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.token == nil {
+		return 0, clipboard.ErrNoChangeToken
+	}
+	return *f.token, nil
+}
+
+// setContent simulates a local copy, bumping the change token if enabled.
+func (f *fakeClipboard) setContent(c *clipboard.Content) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.content = c
+	if f.token != nil {
+		*f.token++
+	}
+}
+
+func (f *fakeClipboard) ReadCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.reads
 }
 
 func (f *fakeClipboard) Read() (*clipboard.Content, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	f.reads++
 	if f.content == nil {
 		return &clipboard.Content{Type: clipboard.TypeText, Data: []byte("")}, nil
 	}
@@ -51,6 +87,19 @@ func (f *fakeClipboard) SetLastHash(hash string) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	f.lastHash = hash
+	if f.store != nil {
+		f.store.SetLastHash(hash)
+	}
+}
+
+func (f *fakeClipboard) MarkSent(hash string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastHash = hash
+	f.sent = append(f.sent, hash)
+	if f.store != nil {
+		f.store.MarkSent(hash)
+	}
 }
 
 func (f *fakeClipboard) WriteCount() int {
@@ -68,6 +117,73 @@ func (f *fakeClipboard) LastWrite() *clipboard.Content {
 	return f.writes[len(f.writes)-1]
 }
 
+// fakeChannel is an in-memory roomChannel that records published messages.
+type fakeChannel struct {
+	mu        sync.Mutex
+	published []string
+	notify    chan struct{}
+}
+
+func newFakeChannel() *fakeChannel {
+	return &fakeChannel{notify: make(chan struct{}, 16)}
+}
+
+func (f *fakeChannel) SubscribeAll(ctx context.Context, handle func(*ably.Message)) (func(), error) {
+	return func() {}, nil
+}
+
+func (f *fakeChannel) Publish(ctx context.Context, name string, data interface{}) error {
+	f.mu.Lock()
+	f.published = append(f.published, data.(string))
+	f.mu.Unlock()
+	f.notify <- struct{}{}
+	return nil
+}
+
+func (f *fakeChannel) Published() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.published...)
+}
+
+// waitPublished blocks until n messages have been published or fails the test.
+func (f *fakeChannel) waitPublished(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.After(2 * time.Second)
+	for len(f.Published()) < n {
+		select {
+		case <-f.notify:
+		case <-deadline:
+			t.Fatalf("timed out waiting for %d publishes, got %d", n, len(f.Published()))
+		}
+	}
+}
+
+// startable gives a test relay the lifecycle fields Start/Stop rely on.
+func startable(r *Relay) *Relay {
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	r.stopChan = make(chan struct{})
+	return r
+}
+
+// decodePublished decrypts a published wire message back to its plaintext.
+func decodePublished(t *testing.T, room *roomSub, raw string) []byte {
+	t.Helper()
+	var msg ablyMsg
+	if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	ct, err := base64.StdEncoding.DecodeString(msg.Data)
+	if err != nil {
+		t.Fatalf("base64: %v", err)
+	}
+	pt, err := decrypt(room.encKey, ct, []byte(room.name))
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	return pt[8:] // strip timestamp
+}
+
 // buildRelay creates a minimal Relay for handleMessage testing (no Ably connection).
 func buildRelay(t *testing.T, room *roomSub, cb *fakeClipboard, sender string, verbose bool) *Relay {
 	t.Helper()
@@ -317,6 +433,19 @@ func TestHandleMessage_ImageType_PreservedOnWrite(t *testing.T) {
 	}
 }
 
+func TestHandleMessage_UnknownContentType_Dropped(t *testing.T) {
+	room := testRoom("hunter2hunter2", "testroom")
+	cb := &fakeClipboard{}
+	r := buildRelay(t, room, cb, "self", false)
+
+	for _, typ := range []uint8{0x00, 0x7F, 0x7F | typeCompressed} {
+		r.handleMessage(room, &ably.Message{Data: makeAblyMsg(t, room, "other", []byte("from the future"), typ)})
+	}
+	if cb.WriteCount() != 0 {
+		t.Errorf("expected unknown content types to be dropped, got %d writes", cb.WriteCount())
+	}
+}
+
 func TestHandleMessage_OldTimestamp_Dropped(t *testing.T) {
 	room := testRoom("hunter2hunter2", "testroom")
 	cb := &fakeClipboard{}
@@ -365,40 +494,15 @@ func TestHandleMessage_TimestampAtWindowEdge_Accepted(t *testing.T) {
 // --- Relay lifecycle tests ---
 
 // TestStopIdempotent verifies that calling Stop() twice does not panic (double
-// close of stopChan was possible before the stopOnce fix).
+// close of stopChan was possible before the stopOnce fix), including on a
+// relay that never connected and so has no client.
 func TestStopIdempotent(t *testing.T) {
 	room := testRoom("hunter2hunter2", "testroom")
 	cb := &fakeClipboard{}
-	r := buildRelay(t, room, cb, "self", false)
-
-	// Manually initialise the fields that Stop() requires so we can call it
-	// without a real Ably connection.
-	ctx, cancel := context.WithCancel(context.Background())
-	r.ctx = ctx
-	r.cancel = cancel
-	r.stopChan = make(chan struct{})
-	// client is nil — Stop calls r.client.Close(), so give it a no-op closer.
-	// We'll recover from any panic instead.
+	r := startable(buildRelay(t, room, cb, "self", false))
 
-	defer func() {
-		if p := recover(); p != nil {
-			t.Errorf("Stop() panicked on second call: %v", p)
-		}
-	}()
-
-	// First stop: closes stopChan and cancels ctx.
-	// Because client is nil this will panic on client.Close(); we use a
-	// narrow sub-relay that skips the client close.  The important invariant
-	// is that the second close(stopChan) must not happen.
-	func() {
-		defer func() { recover() }() // absorb nil-client panic
-		r.Stop()
-	}()
-	// Second call must be a no-op (stopOnce prevents re-entry).
-	func() {
-		defer func() { recover() }()
-		r.Stop()
-	}()
+	r.Stop()
+	r.Stop() // stopOnce makes the second call a no-op
 }
 
 // TestOversizedPayloadDropped verifies that a plaintext payload larger than
@@ -445,3 +549,693 @@ func TestOversizedPayloadDropped(t *testing.T) {
 	t.Logf("maxPlaintextBytes=%d → wire JSON=%d bytes (limit=%d, headroom=%d)",
 		maxPlaintextBytes, len(raw), ablyMessageSizeLimit, ablyMessageSizeLimit-len(raw))
 }
+
+// --- Explicit-push (hotkey) tests ---
+
+func TestPublishOn_TriggerPublishesCurrentClipboard(t *testing.T) {
+	room := testRoom("hunter2hunter2", "testroom")
+	ch := newFakeChannel()
+	room.channel = ch
+	cb := &fakeClipboard{content: &clipboard.Content{Type: clipboard.TypeText, Data: []byte("pushed"), Hash: "h1"}}
+	r := startable(buildRelay(t, room, cb, "self", false))
+
+	trigger := make(chan struct{})
+	r.PublishOn(trigger)
+	r.wg.Add(1)
+	go r.publishOnTrigger()
+	defer func() { close(r.stopChan); r.wg.Wait() }()
+
+	// Nothing is published until the trigger fires.
+	time.Sleep(50 * time.Millisecond)
+	if n := len(ch.Published()); n != 0 {
+		t.Fatalf("expected no publishes before trigger, got %d", n)
+	}
+
+	trigger <- struct{}{}
+	ch.waitPublished(t, 1)
+	if got := decodePublished(t, room, ch.Published()[0]); string(got) != "pushed" {
+		t.Errorf("published %q, want %q", got, "pushed")
+	}
+
+	// A second press re-sends even though the content is unchanged.
+	trigger <- struct{}{}
+	ch.waitPublished(t, 2)
+}
+
+func TestPollAndPublish_SkipsReadWhileChangeTokenUnchanged(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		token     *uint64
+		wantReads func(int) bool
+	}{
+		{"with token", new(uint64), func(n int) bool { return n == 2 }},
+		{"without token", nil, func(n int) bool { return n > 2 }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			room := testRoom("hunter2hunter2", "testroom")
+			ch := newFakeChannel()
+			room.channel = ch
+			cb := &fakeClipboard{token: tc.token}
+			cb.setContent(&clipboard.Content{Type: clipboard.TypeText, Data: []byte("one"), Hash: "h1"})
+			r := startable(buildRelay(t, room, cb, "self", false))
+
+			r.wg.Add(1)
+			go r.pollAndPublish(2 * time.Millisecond)
+			defer func() { close(r.stopChan); r.wg.Wait() }()
+
+			ch.waitPublished(t, 1)
+			time.Sleep(30 * time.Millisecond) // many idle ticks
+
+			cb.setContent(&clipboard.Content{Type: clipboard.TypeText, Data: []byte("two"), Hash: "h2"})
+			ch.waitPublished(t, 2)
+			time.Sleep(30 * time.Millisecond)
+
+			if n := cb.ReadCount(); !tc.wantReads(n) {
+				t.Errorf("unexpected number of reads: %d", n)
+			}
+			if n := len(ch.Published()); n != 2 {
+				t.Errorf("expected 2 publishes, got %d", n)
+			}
+		})
+	}
+}
+
+func TestDirection(t *testing.T) {
+	room := testRoom("hunter2hunter2", "testroom")
+	ch := newFakeChannel()
+	room.channel = ch
+	cb := &fakeClipboard{}
+	r := startable(buildRelay(t, room, cb, "self", false))
+	content := &clipboard.Content{Type: clipboard.TypeText, Data: []byte("local")}
+	incoming := &ably.Message{Data: makeAblyMsg(t, room, "other", []byte("remote"), uint8(clipboard.TypeText))}
+
+	r.SetDirection("testroom", ReceiveOnly)
+	r.publish(content)
+	if n := len(ch.Published()); n != 0 {
+		t.Errorf("receive-only clipboard published %d messages", n)
+	}
+	r.handleMessage(room, incoming)
+	if cb.WriteCount() != 1 {
+		t.Errorf("receive-only clipboard: expected 1 write, got %d", cb.WriteCount())
+	}
+
+	r.SetDirection("testroom", SendOnly)
+	r.handleMessage(room, &ably.Message{Data: makeAblyMsg(t, room, "other", []byte("remote 2"), uint8(clipboard.TypeText))})
+	if cb.WriteCount() != 1 {
+		t.Errorf("send-only clipboard applied incoming content: %d writes", cb.WriteCount())
+	}
+	r.publish(content)
+	if n := len(ch.Published()); n != 1 {
+		t.Errorf("send-only clipboard: expected 1 publish, got %d", n)
+	}
+}
+
+func TestSyncTypes(t *testing.T) {
+	room := testRoom("hunter2hunter2", "testroom")
+	ch := newFakeChannel()
+	room.channel = ch
+	cb := &fakeClipboard{}
+	r := startable(buildRelay(t, room, cb, "self", false))
+	r.SetSyncTypes([]clipboard.ContentType{clipboard.TypeText})
+
+	r.publish(&clipboard.Content{Type: clipboard.TypeImage, Data: []byte("\x89PNG")})
+	if n := len(ch.Published()); n != 0 {
+		t.Errorf("text-only relay published an image (%d messages)", n)
+	}
+	r.handleMessage(room, &ably.Message{Data: makeAblyMsg(t, room, "other", []byte("\x89PNG"), uint8(clipboard.TypeImage))})
+	if cb.WriteCount() != 0 {
+		t.Errorf("text-only relay wrote an incoming image")
+	}
+
+	r.publish(&clipboard.Content{Type: clipboard.TypeText, Data: []byte("words")})
+	if n := len(ch.Published()); n != 1 {
+		t.Errorf("text-only relay: expected 1 text publish, got %d", n)
+	}
+	r.handleMessage(room, &ably.Message{Data: makeAblyMsg(t, room, "other", []byte("more words"), uint8(clipboard.TypeText))})
+	if cb.WriteCount() != 1 {
+		t.Errorf("text-only relay: expected 1 text write, got %d", cb.WriteCount())
+	}
+}
+
+func TestPublish_DownscalesImages(t *testing.T) {
+	room := testRoom("hunter2hunter2", "testroom")
+	ch := newFakeChannel()
+	room.channel = ch
+	r := startable(buildRelay(t, room, &fakeClipboard{}, "self", false))
+	r.SetMaxImageDimension(20)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 80, 40))); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	r.publish(&clipboard.Content{Type: clipboard.TypeImage, Data: buf.Bytes()})
+
+	if n := len(ch.Published()); n != 1 {
+		t.Fatalf("expected 1 publish, got %d", n)
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(decodePublished(t, room, ch.Published()[0])))
+	if err != nil {
+		t.Fatalf("published payload is not a PNG: %v", err)
+	}
+	if cfg.Width != 20 || cfg.Height != 10 {
+		t.Errorf("published %dx%d image, want 20x10", cfg.Width, cfg.Height)
+	}
+}
+
+func TestPublishOnce(t *testing.T) {
+	room := testRoom("hunter2hunter2", "testroom")
+	ch := newFakeChannel()
+	room.channel = ch
+	cb := &fakeClipboard{content: &clipboard.Content{Type: clipboard.TypeText, Data: []byte("one shot"), Hash: "h1"}}
+	r := startable(buildRelay(t, room, cb, "self", false))
+
+	n, err := r.PublishOnce()
+	if err != nil {
+		t.Fatalf("PublishOnce: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("PublishOnce = %d, want 1", n)
+	}
+	if got := decodePublished(t, room, ch.Published()[0]); string(got) != "one shot" {
+		t.Errorf("published %q, want %q", got, "one shot")
+	}
+
+	r.SetDirection("testroom", ReceiveOnly)
+	if n, _ := r.PublishOnce(); n != 0 {
+		t.Errorf("PublishOnce on a receive-only clipboard = %d, want 0", n)
+	}
+}
+
+// --- Injected clock and randomness ---
+
+// fakeClock is a manually advanced clock for deterministic timing tests.
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.t = c.t.Add(d)
+	c.mu.Unlock()
+}
+
+func TestReplayWindow_FakeClock(t *testing.T) {
+	room := testRoom("hunter2hunter2", "testroom")
+	cb := &fakeClipboard{}
+	r := buildRelay(t, room, cb, "self", false)
+	clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+	r.nowFunc = clock.Now
+
+	sentAt := clock.Now().Unix()
+	payload := makeAblyMsgAt(t, room, "remote", []byte("hello"), uint8(clipboard.TypeText), sentAt)
+
+	// Exactly at the window edge the message is still fresh.
+	clock.Advance(replayWindowSeconds * time.Second)
+	r.handleMessage(room, &ably.Message{Data: payload})
+	if cb.WriteCount() != 1 {
+		t.Fatalf("expected message at window edge to be accepted, got %d writes", cb.WriteCount())
+	}
+
+	// One second later the same message is a replay.
+	clock.Advance(time.Second)
+	r.handleMessage(room, &ably.Message{Data: payload})
+	if cb.WriteCount() != 1 {
+		t.Errorf("expected message past the window to be rejected, got %d writes", cb.WriteCount())
+	}
+
+	// Published timestamps come from the same clock.
+	ch := newFakeChannel()
+	room.channel = ch
+	startable(r).publish(&clipboard.Content{Type: clipboard.TypeText, Data: []byte("x")})
+	ch.waitPublished(t, 1)
+	var msg ablyMsg
+	if err := json.Unmarshal([]byte(ch.Published()[0]), &msg); err != nil {
+		t.Fatal(err)
+	}
+	ct, _ := base64.StdEncoding.DecodeString(msg.Data)
+	pt, err := decrypt(room.encKey, ct, []byte(room.name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := int64(binary.BigEndian.Uint64(pt[:8])), clock.Now().Unix(); got != want {
+		t.Errorf("published timestamp %d, want fake clock %d", got, want)
+	}
+}
+
+func TestPublish_DeterministicRandomness(t *testing.T) {
+	room := testRoom("hunter2hunter2", "testroom")
+	clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+	content := &clipboard.Content{Type: clipboard.TypeText, Data: []byte("same")}
+
+	publishOnce := func() string {
+		ch := newFakeChannel()
+		room.channel = ch
+		r := startable(buildRelay(t, room, &fakeClipboard{}, "self", false))
+		r.nowFunc = clock.Now
+		r.rand = bytes.NewReader(bytes.Repeat([]byte{0x42}, 64))
+		r.publish(content)
+		ch.waitPublished(t, 1)
+		return ch.Published()[0]
+	}
+
+	if a, b := publishOnce(), publishOnce(); a != b {
+		t.Error("publish with identical clock and randomness produced different messages")
+	}
+}
+
+// --- Catch-up on connect ---
+
+// fakeBroker is an in-memory stand-in for one Ably channel shared by several
+// relays. It keeps the latest message so subscribers that attach with
+// rewind receive it, mirroring Ably's "rewind" channel param.
+type fakeBroker struct {
+	mu   sync.Mutex
+	last *ably.Message
+	subs map[int]func(*ably.Message)
+	next int
+}
+
+type brokerChannel struct {
+	b      *fakeBroker
+	rewind bool
+}
+
+func (c *brokerChannel) SubscribeAll(ctx context.Context, handle func(*ably.Message)) (func(), error) {
+	b := c.b
+	b.mu.Lock()
+	if b.subs == nil {
+		b.subs = map[int]func(*ably.Message){}
+	}
+	id := b.next
+	b.next++
+	b.subs[id] = handle
+	last := b.last
+	b.mu.Unlock()
+
+	if c.rewind && last != nil {
+		handle(last)
+	}
+	return func() {
+		b.mu.Lock()
+		delete(b.subs, id)
+		b.mu.Unlock()
+	}, nil
+}
+
+func (c *brokerChannel) Publish(ctx context.Context, name string, data interface{}) error {
+	b := c.b
+	msg := &ably.Message{Name: name, Data: data}
+	b.mu.Lock()
+	b.last = msg
+	var handlers []func(*ably.Message)
+	for _, h := range b.subs {
+		handlers = append(handlers, h)
+	}
+	b.mu.Unlock()
+	for _, h := range handlers {
+		h(msg)
+	}
+	return nil
+}
+
+func TestSyncOnConnect_OfflineNodeCatchesUp(t *testing.T) {
+	broker := &fakeBroker{}
+	shared := testRoom("hunter2hunter2", "testroom")
+
+	type node struct {
+		r     *Relay
+		cb    *fakeClipboard
+		room  *roomSub
+		unsub func()
+	}
+	newNode := func(sender string, rewind bool) *node {
+		room := &roomSub{name: shared.name, encKey: shared.encKey, channel: &brokerChannel{b: broker, rewind: rewind}}
+		cb := &fakeClipboard{}
+		r := startable(buildRelay(t, room, cb, sender, false))
+		r.SetSyncOnConnect(rewind)
+		return &node{r: r, cb: cb, room: room}
+	}
+	connect := func(n *node) {
+		unsub, err := n.room.channel.SubscribeAll(n.r.ctx, func(msg *ably.Message) { n.r.handleMessage(n.room, msg) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		n.unsub = unsub
+	}
+
+	a := newNode("node-a", false)
+	b := newNode("node-b", false)
+	c := newNode("node-c", true)
+	connect(a)
+	connect(b)
+	// c is offline while a copies.
+
+	copied := &clipboard.Content{Type: clipboard.TypeText, Data: []byte("copied while c was away")}
+	a.r.publish(copied)
+	if b.cb.WriteCount() != 1 {
+		t.Fatalf("online node b: expected 1 write, got %d", b.cb.WriteCount())
+	}
+	if c.cb.WriteCount() != 0 {
+		t.Fatalf("offline node c should not have received anything yet")
+	}
+
+	// c reconnects and receives the latest content via rewind.
+	connect(c)
+	if c.cb.WriteCount() != 1 {
+		t.Fatalf("reconnected node c: expected 1 catch-up write, got %d", c.cb.WriteCount())
+	}
+	if got := string(c.cb.LastWrite().Data); got != string(copied.Data) {
+		t.Errorf("catch-up delivered %q, want %q", got, copied.Data)
+	}
+
+	// The same item arriving again (a second rewind, or another node
+	// re-sending it) must not be written a second time.
+	c.unsub()
+	connect(c)
+	b.r.publish(copied)
+	if c.cb.WriteCount() != 1 {
+		t.Errorf("duplicate catch-up delivery was written again: %d writes", c.cb.WriteCount())
+	}
+}
+
+func TestSyncOnConnect_ChannelOptions(t *testing.T) {
+	r := &Relay{}
+	if opts := r.channelOptions(); len(opts) != 0 {
+		t.Errorf("expected no channel options by default, got %d", len(opts))
+	}
+	r.SetSyncOnConnect(true)
+	if opts := r.channelOptions(); len(opts) != 1 {
+		t.Errorf("expected rewind channel option, got %d options", len(opts))
+	}
+}
+
+func TestDryRun_NeitherPublishesNorWrites(t *testing.T) {
+	room := testRoom("hunter2hunter2", "testroom")
+	ch := newFakeChannel()
+	room.channel = ch
+	cb := &fakeClipboard{}
+	r := startable(buildRelay(t, room, cb, "self", false))
+	var logs bytes.Buffer
+	r.logger = log.New(&logs, "", 0)
+	r.SetDryRun(true)
+
+	r.publish(&clipboard.Content{Type: clipboard.TypeText, Data: []byte("local"), Hash: "abc123"})
+	if n := len(ch.Published()); n != 0 {
+		t.Errorf("dry run published %d messages", n)
+	}
+	r.handleMessage(room, &ably.Message{Data: makeAblyMsg(t, room, "other", []byte("remote"), uint8(clipboard.TypeText))})
+	if cb.WriteCount() != 0 {
+		t.Errorf("dry run wrote the clipboard %d times", cb.WriteCount())
+	}
+
+	for _, want := range []string{"would publish text (5 bytes, hash abc123)", "would write text (6 bytes"} {
+		if !bytes.Contains(logs.Bytes(), []byte(want)) {
+			t.Errorf("log missing %q:\n%s", want, logs.String())
+		}
+	}
+	if st := r.Status()[0]; st.ItemsSent != 0 || st.ItemsReceived != 0 {
+		t.Errorf("dry run counted traffic: %+v", st)
+	}
+}
+
+func TestExcludePatterns(t *testing.T) {
+	room := testRoom("hunter2hunter2", "testroom")
+	ch := newFakeChannel()
+	room.channel = ch
+	r := startable(buildRelay(t, room, &fakeClipboard{}, "self", false))
+	var logs bytes.Buffer
+	r.logger = log.New(&logs, "", 0)
+	r.SetExcludePatterns([]*regexp.Regexp{regexp.MustCompile(`^sk-[A-Za-z0-9]{20,}$`)})
+
+	secret := "sk-abcdefghijklmnopqrstuvwxyz"
+	r.publish(&clipboard.Content{Type: clipboard.TypeText, Data: []byte(secret)})
+	if n := len(ch.Published()); n != 0 {
+		t.Errorf("excluded text published %d messages", n)
+	}
+	if bytes.Contains(logs.Bytes(), []byte(secret)) {
+		t.Error("log contains the excluded content")
+	}
+
+	r.publish(&clipboard.Content{Type: clipboard.TypeText, Data: []byte("just words")})
+	if n := len(ch.Published()); n != 1 {
+		t.Errorf("ordinary text: expected 1 publish, got %d", n)
+	}
+}
+
+func TestConcealedContentNotPublished(t *testing.T) {
+	room := testRoom("hunter2hunter2", "testroom")
+	ch := newFakeChannel()
+	room.channel = ch
+	r := startable(buildRelay(t, room, &fakeClipboard{}, "self", false))
+	secret := &clipboard.Content{Type: clipboard.TypeText, Data: []byte("p4ssw0rd"), Concealed: true}
+
+	r.publish(secret)
+	if n := len(ch.Published()); n != 0 {
+		t.Errorf("concealed content published %d messages", n)
+	}
+
+	r.SetSyncConcealed(true)
+	r.publish(secret)
+	if n := len(ch.Published()); n != 1 {
+		t.Errorf("with SetSyncConcealed: expected 1 publish, got %d", n)
+	}
+}
+
+func TestPollAndPublish_PersistsOnlyPublishedHashes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "last_hash")
+	store := clipboard.New(nil)
+	if err := store.PersistLastHash(path); err != nil {
+		t.Fatalf("PersistLastHash: %v", err)
+	}
+
+	room := testRoom("hunter2hunter2", "testroom")
+	ch := newFakeChannel()
+	room.channel = ch
+	cb := &fakeClipboard{token: new(uint64), store: store}
+	r := startable(buildRelay(t, room, cb, "self", false))
+	r.SetExcludePatterns([]*regexp.Regexp{regexp.MustCompile(`^sk-`)})
+
+	r.wg.Add(1)
+	go r.pollAndPublish(2 * time.Millisecond)
+	defer func() { close(r.stopChan); r.wg.Wait() }()
+
+	concealed := []byte("p4ssw0rd")
+	excluded := []byte("sk-0123456789abcdefghij")
+	for _, c := range []*clipboard.Content{
+		{Type: clipboard.TypeText, Data: concealed, Hash: clipboard.HashData(concealed), Concealed: true},
+		{Type: clipboard.TypeText, Data: excluded, Hash: clipboard.HashData(excluded)},
+	} {
+		cb.setContent(c)
+		time.Sleep(20 * time.Millisecond)
+	}
+	if n := len(ch.Published()); n != 0 {
+		t.Fatalf("published %d messages, want 0", n)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("hash file written for content that was not published (stat err %v)", err)
+	}
+
+	plain := []byte("just words")
+	cb.setContent(&clipboard.Content{Type: clipboard.TypeText, Data: plain, Hash: clipboard.HashData(plain)})
+	ch.waitPublished(t, 1)
+	time.Sleep(20 * time.Millisecond)
+
+	// Each check uses a fresh clipboard, as after a restart.
+	recorded := func(data []byte) bool {
+		restarted := clipboard.New(nil)
+		if err := restarted.PersistLastHash(path); err != nil {
+			t.Fatalf("PersistLastHash after restart: %v", err)
+		}
+		return !restarted.HasChanged(clipboard.HashData(data))
+	}
+	if !recorded(plain) {
+		t.Error("published content not recorded in the hash file")
+	}
+	for _, data := range [][]byte{concealed, excluded} {
+		if recorded(data) {
+			t.Errorf("%q recorded in the hash file", data)
+		}
+	}
+}
+
+func TestPollAndPublish_CoalescesBursts(t *testing.T) {
+	room := testRoom("hunter2hunter2", "testroom")
+	ch := newFakeChannel()
+	room.channel = ch
+	cb := &fakeClipboard{}
+	cb.setContent(&clipboard.Content{Type: clipboard.TypeText, Data: []byte("one"), Hash: "h1"})
+	r := startable(buildRelay(t, room, cb, "self", false))
+	clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+	r.nowFunc = clock.Now
+	r.SetCoalesce(time.Second)
+
+	r.wg.Add(1)
+	go r.pollAndPublish(2 * time.Millisecond)
+	defer func() { close(r.stopChan); r.wg.Wait() }()
+
+	for _, s := range []string{"two", "three"} {
+		time.Sleep(10 * time.Millisecond)
+		cb.setContent(&clipboard.Content{Type: clipboard.TypeText, Data: []byte(s), Hash: s})
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := len(ch.Published()); n != 0 {
+		t.Fatalf("published %d messages during the burst, want 0", n)
+	}
+
+	clock.Advance(time.Second)
+	ch.waitPublished(t, 1)
+	time.Sleep(20 * time.Millisecond)
+	if n := len(ch.Published()); n != 1 {
+		t.Fatalf("expected 1 publish after the quiet period, got %d", n)
+	}
+	if got := decodePublished(t, room, ch.Published()[0]); string(got) != "three" {
+		t.Errorf("published %q, want the last change %q", got, "three")
+	}
+}
+
+func TestOnContent_CanVetoWrites(t *testing.T) {
+	room := testRoom("hunter2hunter2", "testroom")
+	cb := &fakeClipboard{}
+	r := buildRelay(t, room, cb, "self", false)
+	var seen []string
+	r.OnContent(func(c *clipboard.Content) bool {
+		seen = append(seen, string(c.Data))
+		return string(c.Data) != "blocked"
+	})
+
+	r.handleMessage(room, &ably.Message{Data: makeAblyMsg(t, room, "other", []byte("blocked"), uint8(clipboard.TypeText))})
+	if cb.WriteCount() != 0 {
+		t.Errorf("vetoed content was written")
+	}
+	r.handleMessage(room, &ably.Message{Data: makeAblyMsg(t, room, "other", []byte("allowed"), uint8(clipboard.TypeText))})
+	if cb.WriteCount() != 1 || string(cb.LastWrite().Data) != "allowed" {
+		t.Errorf("expected allowed content to be written, got %d writes", cb.WriteCount())
+	}
+	if len(seen) != 2 {
+		t.Errorf("hook saw %v, want both items", seen)
+	}
+}
+
+func TestConnectionTransition(t *testing.T) {
+	tests := []struct {
+		prev, cur     ably.ConnectionState
+		connected, ok bool
+	}{
+		{ably.ConnectionStateConnecting, ably.ConnectionStateConnected, true, true},
+		{ably.ConnectionStateConnected, ably.ConnectionStateDisconnected, false, true},
+		{ably.ConnectionStateConnected, ably.ConnectionStateClosed, false, true},
+		{ably.ConnectionStateConnected, ably.ConnectionStateConnected, false, false}, // UPDATE event
+		{ably.ConnectionStateDisconnected, ably.ConnectionStateConnecting, false, false},
+	}
+	for _, tt := range tests {
+		connected, ok := connectionTransition(ably.ConnectionStateChange{Previous: tt.prev, Current: tt.cur})
+		if connected != tt.connected || ok != tt.ok {
+			t.Errorf("%v -> %v: got (%v, %v), want (%v, %v)", tt.prev, tt.cur, connected, ok, tt.connected, tt.ok)
+		}
+	}
+}
+
+func TestShutdown_FlushesCoalescedChange(t *testing.T) {
+	room := testRoom("hunter2hunter2", "testroom")
+	ch := newFakeChannel()
+	room.channel = ch
+	cb := &fakeClipboard{}
+	cb.setContent(&clipboard.Content{Type: clipboard.TypeText, Data: []byte("one"), Hash: "h1"})
+	r := startable(buildRelay(t, room, cb, "self", false))
+	r.SetCoalesce(time.Hour)
+
+	r.wg.Add(1)
+	go r.pollAndPublish(2 * time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if n := len(ch.Published()); n != 0 {
+		t.Fatalf("published %d messages before the quiet period, want 0", n)
+	}
+
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if n := len(ch.Published()); n != 1 {
+		t.Fatalf("expected the pending change to be published on Shutdown, got %d messages", n)
+	}
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Errorf("second Shutdown: %v", err)
+	}
+}
+
+func TestPollAndPublish_ReceivedContentSupersedesPending(t *testing.T) {
+	for _, flush := range []string{"quiet period", "shutdown"} {
+		t.Run(flush, func(t *testing.T) {
+			room := testRoom("hunter2hunter2", "testroom")
+			ch := newFakeChannel()
+			room.channel = ch
+			cb := &fakeClipboard{}
+			cb.setContent(&clipboard.Content{Type: clipboard.TypeText, Data: []byte("local"), Hash: "local"})
+			r := startable(buildRelay(t, room, cb, "self", false))
+			clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+			r.nowFunc = clock.Now
+			r.SetCoalesce(time.Second)
+
+			r.wg.Add(1)
+			go r.pollAndPublish(2 * time.Millisecond)
+			time.Sleep(20 * time.Millisecond) // "local" is now pending
+
+			r.handleMessage(room, &ably.Message{Data: makeAblyMsgAt(t, room, "other", []byte("remote"), uint8(clipboard.TypeText), clock.Now().Unix())})
+			if cb.WriteCount() != 1 {
+				t.Fatalf("received content not written")
+			}
+
+			if flush == "shutdown" {
+				if err := r.Shutdown(context.Background()); err != nil {
+					t.Fatalf("Shutdown: %v", err)
+				}
+			} else {
+				clock.Advance(time.Second)
+				time.Sleep(20 * time.Millisecond)
+				close(r.stopChan)
+				r.wg.Wait()
+			}
+			if n := len(ch.Published()); n != 0 {
+				t.Errorf("stale pending change published %d times after newer content arrived", n)
+			}
+		})
+	}
+}
+
+// stuckChannel never completes a publish until its context is cancelled.
+type stuckChannel struct{ started chan struct{} }
+
+func (s *stuckChannel) SubscribeAll(ctx context.Context, handle func(*ably.Message)) (func(), error) {
+	return func() {}, nil
+}
+
+func (s *stuckChannel) Publish(ctx context.Context, name string, data interface{}) error {
+	close(s.started)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestShutdown_TimeoutCancelsInFlightPublish(t *testing.T) {
+	room := testRoom("hunter2hunter2", "testroom")
+	ch := &stuckChannel{started: make(chan struct{})}
+	room.channel = ch
+	cb := &fakeClipboard{}
+	cb.setContent(&clipboard.Content{Type: clipboard.TypeText, Data: []byte("one"), Hash: "h1"})
+	r := startable(buildRelay(t, room, cb, "self", false))
+
+	r.wg.Add(1)
+	go r.pollAndPublish(2 * time.Millisecond)
+	<-ch.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := r.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,111 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// PassphraseFunc returns the passphrase for the named clipboard.
+// GetPassphrase (the system keychain) is the default implementation.
+type PassphraseFunc func(name string) (string, error)
+
+// SecretSource describes where a daemon reads a clipboard passphrase from
+// when the keychain is not usable, e.g. under launchd/systemd or on a
+// headless box. At most one field may be set. There is deliberately no way
+// to pass the secret itself on the command line, where it would be visible
+// in ps output.
+type SecretSource struct {
+	Env    string // name of an environment variable holding the secret
+	File   string // path to a file whose contents are the secret
+	FD     int    // inherited file descriptor to read the secret from; -1 = unset
+	Prompt bool   // ask on the terminal, without echo
+}
+
+// IsSet reports whether any source has been configured.
+func (s SecretSource) IsSet() bool {
+	return s.Env != "" || s.File != "" || s.FD >= 0 || s.Prompt
+}
+
+// Validate returns an error if more than one source is configured.
+func (s SecretSource) Validate() error {
+	n := 0
+	if s.Env != "" {
+		n++
+	}
+	if s.File != "" {
+		n++
+	}
+	if s.FD >= 0 {
+		n++
+	}
+	if s.Prompt {
+		n++
+	}
+	if n > 1 {
+		return errors.New("only one of -passphrase-env, -passphrase-file, -passphrase-fd and -passphrase-prompt may be set")
+	}
+	return nil
+}
+
+// Read resolves the secret from the configured source. A single trailing
+// newline is stripped so files written with echo work as expected.
+func (s SecretSource) Read() (string, error) {
+	if err := s.Validate(); err != nil {
+		return "", err
+	}
+
+	var raw string
+	switch {
+	case s.Env != "":
+		v, ok := os.LookupEnv(s.Env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", s.Env)
+		}
+		raw = v
+	case s.File != "":
+		data, err := os.ReadFile(s.File)
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase file: %w", err)
+		}
+		raw = string(data)
+	case s.FD >= 0:
+		f := os.NewFile(uintptr(s.FD), "passphrase-fd")
+		if f == nil {
+			return "", fmt.Errorf("invalid passphrase file descriptor %d", s.FD)
+		}
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase from fd %d: %w", s.FD, err)
+		}
+		raw = string(data)
+	case s.Prompt:
+		line, err := promptPassphrase(os.Stdin, os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		raw = line
+	default:
+		return "", errors.New("no passphrase source configured")
+	}
+
+	raw = strings.TrimSuffix(raw, "\n")
+	raw = strings.TrimSuffix(raw, "\r")
+	if len(raw) < minPassphraseLen {
+		return "", fmt.Errorf("passphrase must be at least %d characters", minPassphraseLen)
+	}
+	return raw, nil
+}
+
+// PassphraseFunc returns a lookup that resolves the secret once and hands
+// the same passphrase to every clipboard.
+func (s SecretSource) PassphraseFunc() (PassphraseFunc, error) {
+	pass, err := s.Read()
+	if err != nil {
+		return nil, err
+	}
+	return func(string) (string, error) { return pass, nil }, nil
+}
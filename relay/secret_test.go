@@ -0,0 +1,97 @@
+package relay
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSecret = "correct horse battery"
+
+func TestSecretSourceEnv(t *testing.T) {
+	t.Setenv("PAPERCLIP_TEST_PASSPHRASE", testSecret)
+	got, err := SecretSource{Env: "PAPERCLIP_TEST_PASSPHRASE", FD: -1}.Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if got != testSecret {
+		t.Errorf("got %q, want %q", got, testSecret)
+	}
+}
+
+func TestSecretSourceEnvUnset(t *testing.T) {
+	if _, err := (SecretSource{Env: "PAPERCLIP_TEST_UNSET_VAR", FD: -1}).Read(); err == nil {
+		t.Error("expected error for unset environment variable")
+	}
+}
+
+func TestSecretSourceFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "passphrase")
+	if err := os.WriteFile(path, []byte(testSecret+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := SecretSource{File: path, FD: -1}.Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if got != testSecret {
+		t.Errorf("got %q, want %q (trailing newline should be stripped)", got, testSecret)
+	}
+}
+
+func TestSecretSourceTooShort(t *testing.T) {
+	t.Setenv("PAPERCLIP_TEST_PASSPHRASE", "short")
+	if _, err := (SecretSource{Env: "PAPERCLIP_TEST_PASSPHRASE", FD: -1}).Read(); err == nil {
+		t.Error("expected error for passphrase shorter than minimum")
+	}
+}
+
+func TestSecretSourceMutuallyExclusive(t *testing.T) {
+	cases := []SecretSource{
+		{Env: "A", File: "/tmp/x", FD: -1},
+		{Env: "A", FD: 3},
+		{File: "/tmp/x", FD: 3},
+		{Env: "A", FD: -1, Prompt: true},
+	}
+	for _, s := range cases {
+		if err := s.Validate(); err == nil {
+			t.Errorf("expected validation error for %+v", s)
+		}
+	}
+	if err := (SecretSource{Env: "A", FD: -1}).Validate(); err != nil {
+		t.Errorf("single source should validate, got %v", err)
+	}
+	if (SecretSource{FD: -1}).IsSet() {
+		t.Error("empty source should not report IsSet")
+	}
+}
+
+func TestPromptPassphraseNeedsTerminal(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	if _, err := promptPassphrase(r, io.Discard); !errors.Is(err, errNotTerminal) {
+		t.Errorf("prompt on a pipe: got %v, want errNotTerminal", err)
+	}
+}
+
+func TestReadLineStopsAtNewline(t *testing.T) {
+	in := strings.NewReader(testSecret + "\r\nrest")
+	got, err := readLine(in)
+	if err != nil {
+		t.Fatalf("readLine: %v", err)
+	}
+	if got != testSecret+"\r\n" {
+		t.Errorf("got %q, want %q", got, testSecret+"\r\n")
+	}
+	if rest, _ := io.ReadAll(in); string(rest) != "rest" {
+		t.Errorf("readLine consumed past the newline; left %q", rest)
+	}
+}
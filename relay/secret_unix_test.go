@@ -0,0 +1,35 @@
+//go:build !windows
+
+package relay
+
+import (
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestSecretSourceFD(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteString(testSecret); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	defer r.Close()
+
+	// Read takes ownership of the descriptor and closes it, so hand it a
+	// duplicate rather than the one r will close.
+	fd, err := syscall.Dup(int(r.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := SecretSource{FD: fd}.Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if got != testSecret {
+		t.Errorf("got %q, want %q", got, testSecret)
+	}
+}
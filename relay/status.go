@@ -0,0 +1,54 @@
+package relay
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// StatusReport is the JSON document served at /status.
+type StatusReport struct {
+	Connected  bool              `json:"connected"`
+	LastSyncAt *time.Time        `json:"last_sync_at,omitempty"`
+	Clipboards []ClipboardStatus `json:"clipboards"`
+}
+
+// Report returns a point-in-time snapshot of the relay's state.
+func (r *Relay) Report() StatusReport {
+	report := StatusReport{
+		Connected:  r.Connected(),
+		Clipboards: r.Status(),
+	}
+	if t := r.LastSyncAt(); !t.IsZero() {
+		report.LastSyncAt = &t
+	}
+	return report
+}
+
+// StatusHandler serves a read-only JSON status document at /status, and the
+// same counters in Prometheus text format at /metrics, for the relay returned
+// by current, which may change over time (the tray replaces
+// its relay when settings change). If current returns nil the endpoint
+// answers 503.
+func StatusHandler(current func() *Relay) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, req *http.Request) {
+		r := current()
+		if r == nil {
+			http.Error(w, "relay not running", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(r.Report())
+	})
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, req *http.Request) {
+		r := current()
+		if r == nil {
+			http.Error(w, "relay not running", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeMetrics(w, r.Report())
+	})
+	return mux
+}
@@ -0,0 +1,101 @@
+package relay
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ably/ably-go/ably"
+	"github.com/mindmorass/paperclip/clipboard"
+)
+
+func getStatus(t *testing.T, h http.Handler) (int, StatusReport) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/status", nil))
+	var report StatusReport
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("decode status: %v", err)
+		}
+	}
+	return rec.Code, report
+}
+
+func TestStatusHandler_CountsTraffic(t *testing.T) {
+	broker := &fakeBroker{}
+	shared := testRoom("hunter2hunter2", "testroom")
+
+	roomA := &roomSub{name: shared.name, encKey: shared.encKey, channel: &brokerChannel{b: broker}}
+	roomB := &roomSub{name: shared.name, encKey: shared.encKey, channel: &brokerChannel{b: broker}}
+	a := startable(buildRelay(t, roomA, &fakeClipboard{}, "node-a", false))
+	b := startable(buildRelay(t, roomB, &fakeClipboard{}, "node-b", false))
+	if _, err := roomB.channel.SubscribeAll(b.ctx, func(msg *ably.Message) { b.handleMessage(roomB, msg) }); err != nil {
+		t.Fatal(err)
+	}
+
+	a.publish(&clipboard.Content{Type: clipboard.TypeText, Data: []byte("hello")})
+
+	code, report := getStatus(t, StatusHandler(func() *Relay { return a }))
+	if code != http.StatusOK {
+		t.Fatalf("status code = %d, want 200", code)
+	}
+	if len(report.Clipboards) != 1 {
+		t.Fatalf("expected 1 clipboard, got %d", len(report.Clipboards))
+	}
+	if cs := report.Clipboards[0]; cs.Name != "testroom" || !cs.Encrypted || cs.ItemsSent != 1 || cs.BytesSent != 5 || cs.ItemsReceived != 0 {
+		t.Errorf("sender status = %+v", cs)
+	}
+	if report.LastSyncAt == nil {
+		t.Error("expected last_sync_at after a publish")
+	}
+
+	_, report = getStatus(t, StatusHandler(func() *Relay { return b }))
+	if cs := report.Clipboards[0]; cs.ItemsReceived != 1 || cs.BytesReceived != 5 || cs.ItemsSent != 0 {
+		t.Errorf("receiver status = %+v", cs)
+	}
+}
+
+func TestStatusHandler_NoRelay(t *testing.T) {
+	if code, _ := getStatus(t, StatusHandler(func() *Relay { return nil })); code != http.StatusServiceUnavailable {
+		t.Errorf("status code = %d, want 503", code)
+	}
+}
+
+func TestStatusHandler_Metrics(t *testing.T) {
+	broker := &fakeBroker{}
+	shared := testRoom("hunter2hunter2", "test\"room")
+
+	roomA := &roomSub{name: shared.name, encKey: shared.encKey, channel: &brokerChannel{b: broker}}
+	roomB := &roomSub{name: shared.name, encKey: shared.encKey, channel: &brokerChannel{b: broker}}
+	a := startable(buildRelay(t, roomA, &fakeClipboard{}, "node-a", false))
+	b := startable(buildRelay(t, roomB, &fakeClipboard{}, "node-b", false))
+	if _, err := roomB.channel.SubscribeAll(b.ctx, func(msg *ably.Message) { b.handleMessage(roomB, msg) }); err != nil {
+		t.Fatal(err)
+	}
+
+	content := &clipboard.Content{Type: clipboard.TypeText, Data: []byte("hello")}
+	a.publish(content)
+	a.publish(content) // b already holds it
+
+	rec := httptest.NewRecorder()
+	StatusHandler(func() *Relay { return b }).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status code = %d, want 200", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE paperclip_items_received_total counter\n",
+		`paperclip_items_received_total{clipboard="test\"room"} 1` + "\n",
+		`paperclip_bytes_received_total{clipboard="test\"room"} 5` + "\n",
+		`paperclip_dedup_dropped_total{clipboard="test\"room"} 1` + "\n",
+		`paperclip_auth_failures_total{clipboard="test\"room"} 0` + "\n",
+		"paperclip_last_sync_timestamp_seconds ",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
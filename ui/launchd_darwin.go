@@ -7,7 +7,6 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
 
 	"github.com/mindmorass/paperclip/config"
 )
@@ -38,14 +37,11 @@ func installLaunchAgent(cfg *config.Config) error {
 		return err
 	}
 
-	// Build clipboard names for --clipboard flag.
-	var roomNames []string
-	for _, r := range cfg.Relay.EnabledClipboards() {
-		roomNames = append(roomNames, r.Name)
-	}
-
-	// The API key is read from the system keychain at runtime — not embedded in
-	// the plist — so no sensitive credentials appear in the LaunchAgent file.
+	// Only the executable is recorded — no flags. Clipboards, poll rate and
+	// the rest are read from config.json at startup, so changes made via the
+	// tray take effect on the next launch instead of being shadowed by stale
+	// arguments. The API key is read from the system keychain at runtime, so
+	// no sensitive credentials appear in the LaunchAgent file either.
 	plist := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
 <!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
 <plist version="1.0">
@@ -55,10 +51,6 @@ func installLaunchAgent(cfg *config.Config) error {
     <key>ProgramArguments</key>
     <array>
         <string>%s</string>
-        <string>-poll</string>
-        <string>%d</string>
-        <string>-clipboard</string>
-        <string>%s</string>
     </array>
     <key>RunAtLoad</key>
     <true/>
@@ -70,7 +62,7 @@ func installLaunchAgent(cfg *config.Config) error {
     <string>%s/Library/Logs/paperclip.err</string>
 </dict>
 </plist>
-`, plistLabel, execPath, cfg.PollMs, strings.Join(roomNames, ","), home, home)
+`, plistLabel, execPath, home, home)
 
 	dir := filepath.Dir(plistPath())
 	if err := os.MkdirAll(dir, 0755); err != nil {
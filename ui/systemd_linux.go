@@ -0,0 +1,91 @@
+//go:build linux
+
+package ui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mindmorass/paperclip/config"
+)
+
+const systemdUnitName = "paperclip.service"
+
+func unitPath() (string, error) {
+	base, err := os.UserConfigDir() // honours $XDG_CONFIG_HOME
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "systemd", "user", systemdUnitName), nil
+}
+
+// WriteSystemdUnit writes a systemd user unit that runs the current
+// executable and returns its path. As with the macOS LaunchAgent, no flags
+// are recorded: settings come from config.json at startup.
+func WriteSystemdUnit() (string, error) {
+	execPath, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("could not determine executable path: %w", err)
+	}
+	if strings.ContainsAny(execPath, " \t") {
+		execPath = `"` + execPath + `"`
+	}
+
+	unit := fmt.Sprintf(`[Unit]
+Description=Paperclip clipboard sync
+After=network-online.target
+
+[Service]
+ExecStart=%s
+Restart=on-failure
+RestartSec=5
+
+[Install]
+WantedBy=default.target
+`, execPath)
+
+	path, err := unitPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(unit), 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func isLaunchAgentInstalled() bool {
+	path, err := unitPath()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+func installLaunchAgent(cfg *config.Config) error {
+	if _, err := WriteSystemdUnit(); err != nil {
+		return err
+	}
+	if err := exec.Command("systemctl", "--user", "daemon-reload").Run(); err != nil {
+		return fmt.Errorf("systemctl daemon-reload: %w", err)
+	}
+	return exec.Command("systemctl", "--user", "enable", "--now", systemdUnitName).Run()
+}
+
+func uninstallLaunchAgent() error {
+	if err := exec.Command("systemctl", "--user", "disable", "--now", systemdUnitName).Run(); err != nil {
+		return fmt.Errorf("systemctl disable: %w", err)
+	}
+	path, err := unitPath()
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
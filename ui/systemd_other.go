@@ -0,0 +1,10 @@
+//go:build !linux
+
+package ui
+
+import "errors"
+
+// WriteSystemdUnit is only available on Linux.
+func WriteSystemdUnit() (string, error) {
+	return "", errors.New("systemd units are only supported on Linux")
+}